@@ -0,0 +1,103 @@
+package ConcurrentSkipList
+
+import (
+	"sort"
+)
+
+// Entry is an index and its value for InsertBatch.
+type Entry struct {
+	Index uint64
+	Value interface{}
+}
+
+// InsertBatch will insert the entries into skip list. The entries are sorted by index and grouped by shard,
+// then each shard is locked once and each index is searched from the previous nodes of the last one,
+// so inserting sorted entries costs about linear time. If an index appears more than once, the last value wins.
+// Entries with nil value are ignored like Insert. The given slice is not modified.
+// The batch is not atomic, the readers may see part of it.
+func (s *ConcurrentSkipList) InsertBatch(entries []Entry) {
+	batch := make([]Entry, 0, len(entries))
+	for _, entry := range entries {
+		// Ignore nil value.
+		if entry.Value != nil {
+			batch = append(batch, entry)
+		}
+	}
+
+	if o, ok := s.loadTable().partitioner.(observer); ok {
+		for _, entry := range batch {
+			o.observe(entry.Index)
+		}
+	}
+
+	forEachShard(s, batch, func(entry Entry) uint64 {
+		return entry.Index
+	}, func(sl shard, group []Entry) bool {
+		if !sl.insertBatch(group) {
+			return false
+		}
+
+		s.checkSplit(sl)
+		return true
+	})
+	s.checkCapacity()
+}
+
+// DeleteBatch will delete the indexes from skip list. Like InsertBatch, the indexes are sorted and grouped
+// by shard, and each shard is locked once. The given slice is not modified. The batch is not atomic.
+func (s *ConcurrentSkipList) DeleteBatch(indexes []uint64) {
+	batch := append([]uint64(nil), indexes...)
+	forEachShard(s, batch, func(index uint64) uint64 {
+		return index
+	}, func(sl shard, group []uint64) bool {
+		if !sl.deleteBatch(group) {
+			return false
+		}
+
+		s.checkMerge(sl)
+		return true
+	})
+}
+
+// forEachShard will sort the items by shard and index, then call f() with the items of each shard
+// of the current routing table. If f() returns false because the shard is retired, the remaining
+// items are routed by the new routing table. Items with the same index keep their order.
+func forEachShard[T any](s *ConcurrentSkipList, items []T, index func(T) uint64, f func(sl shard, group []T) bool) {
+	table := s.loadTable()
+	sortByShard(table, items, index)
+	for len(items) > 0 {
+		shardIndex := table.partitioner.Shard(index(items[0]))
+		n := 1
+		for n < len(items) && table.partitioner.Shard(index(items[n])) == shardIndex {
+			n++
+		}
+
+		if f(table.skipLists[shardIndex], items[:n]) {
+			items = items[n:]
+			continue
+		}
+
+		table = s.loadTable()
+		sortByShard(table, items, index)
+	}
+}
+
+// sortByShard will sort the items by shard and index stably. If the shards are ordered, sorting by index is enough.
+// Sorted items are checked first, so the cost is linear for them.
+func sortByShard[T any](table *routingTable, items []T, index func(T) uint64) {
+	ordered := table.partitioner.Ordered()
+	less := func(i, j int) bool {
+		a, b := index(items[i]), index(items[j])
+		if !ordered {
+			if shardA, shardB := table.partitioner.Shard(a), table.partitioner.Shard(b); shardA != shardB {
+				return shardA < shardB
+			}
+		}
+
+		return a < b
+	}
+
+	if !sort.SliceIsSorted(items, less) {
+		sort.SliceStable(items, less)
+	}
+}
@@ -0,0 +1,126 @@
+package ConcurrentSkipList
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentSkipList_Batch(t *testing.T) {
+	hash, _ := NewHashPartitioner(4)
+	tests := []struct {
+		name    string
+		options Options
+	}{
+		{"locked", Options{MaxLevel: 12, Shards: 4}},
+		{"lock-free", Options{MaxLevel: 12, Shards: 4, LockFree: true}},
+		{"hash", Options{MaxLevel: 12, Partitioner: hash}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skipList, _ := NewConcurrentSkipListWithOptions(tt.options)
+			random := rand.New(rand.NewSource(1))
+			want := make(map[uint64]interface{})
+			for i := 0; i < 1000; i++ {
+				index := random.Uint64() % 2000
+				skipList.Insert(index, -1)
+				want[index] = -1
+			}
+
+			// Unsorted entries with duplicated indexes and nil values.
+			var entries []Entry
+			for i := 0; i < 5000; i++ {
+				index := random.Uint64()%2000 + uint64(i%4)*shardIndexes[0]
+				entries = append(entries, Entry{Index: index, Value: i})
+				want[index] = i
+			}
+
+			entries = append(entries, Entry{Index: 1, Value: nil})
+			skipList.InsertBatch(entries)
+
+			var indexes []uint64
+			for i := 0; i < 3000; i++ {
+				index := random.Uint64()%2000 + uint64(i%4)*shardIndexes[0]
+				indexes = append(indexes, index)
+				delete(want, index)
+			}
+
+			skipList.DeleteBatch(indexes)
+
+			t.Run("test content", func(t *testing.T) {
+				if length := skipList.Length(); length != int32(len(want)) {
+					t.Errorf("skip list's length is not correct, got %d, want %d", length, len(want))
+				}
+
+				for index, value := range want {
+					if node, ok := skipList.Search(index); !ok || node.Value() != value {
+						t.Fatalf("Search(%v) = %v, want %v", index, node, value)
+					}
+				}
+			})
+
+			t.Run("test rank", func(t *testing.T) {
+				if !skipList.loadTable().partitioner.Ordered() {
+					t.Skip("positions are global only for ordered shards")
+				}
+
+				var position int32
+				skipList.ForEach(func(node *Node) bool {
+					if got, ok := skipList.Rank(node.Index()); !ok || got != position {
+						t.Fatalf("Rank(%v) = %v, want %v", node.Index(), got, position)
+					}
+
+					if got, ok := skipList.At(position); !ok || got.Index() != node.Index() {
+						t.Fatalf("At(%v) = %v, want %v", position, got, node.Index())
+					}
+
+					position++
+					return true
+				})
+			})
+		})
+	}
+}
+
+func TestConcurrentSkipList_Batch_Balance(t *testing.T) {
+	partitioner, _ := NewRangePartitioner()
+	skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, Partitioner: partitioner, SplitLength: 500})
+	count := 10000
+
+	// The shards are split while inserting, the batches on retired shards are routed again.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := i * 100; j < count; j += 400 {
+				var entries []Entry
+				for k := j; k < j+100; k++ {
+					entries = append(entries, Entry{Index: uint64(k), Value: k})
+				}
+
+				skipList.InsertBatch(entries)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	skipList.Balance()
+	if length := skipList.Length(); length != int32(count) {
+		t.Errorf("skip list's length is not correct, got %d", length)
+	}
+
+	if len(skipList.loadTable().skipLists) < 2 {
+		t.Errorf("shards should be split")
+	}
+
+	i := 0
+	skipList.ForEach(func(node *Node) bool {
+		if node.Index() != uint64(i) || node.Value() != i {
+			t.Fatalf("ForEach() = %v, want = %v", node.Index(), i)
+		}
+
+		i++
+		return true
+	})
+}
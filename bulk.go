@@ -0,0 +1,52 @@
+package ConcurrentSkipList
+
+import (
+	"fmt"
+	"iter"
+)
+
+// FromSorted will create a new concurrent skip list with given options and load the entries of seq.
+// The indexes must be strictly ascending, otherwise return an error. Entries with nil value are ignored.
+// Each node is appended to the end of its shard with a random level directly instead of being searched
+// and inserted, so loading n entries costs O(n). For example, restore a skip list from another one:
+//
+//	skipList, err := FromSorted(Options{MaxLevel: 12}, other.All())
+func FromSorted(options Options, seq iter.Seq2[uint64, interface{}]) (*ConcurrentSkipList, error) {
+	s, err := NewConcurrentSkipListWithOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	table := s.loadTable()
+	builders := make([]builder, len(table.skipLists))
+	for i, sl := range table.skipLists {
+		builders[i] = sl.newBuilder()
+	}
+
+	first := true
+	var last uint64
+	for index, value := range seq {
+		if !first && index <= last {
+			return nil, fmt.Errorf("invalid order, index %d is not greater than the previous index %d", index, last)
+		}
+
+		first, last = false, index
+		// Ignore nil value.
+		if value == nil {
+			continue
+		}
+
+		if o, ok := table.partitioner.(observer); ok {
+			o.observe(index)
+		}
+
+		builders[table.partitioner.Shard(index)].append(index, value)
+	}
+
+	for _, b := range builders {
+		b.finish()
+	}
+
+	s.checkCapacity()
+	return s, nil
+}
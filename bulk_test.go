@@ -0,0 +1,97 @@
+package ConcurrentSkipList
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFromSorted(t *testing.T) {
+	source, _ := NewConcurrentSkipList(12)
+	count := 10000
+	for i := 0; i < count; i++ {
+		source.Insert(uint64(i*3), i)
+	}
+
+	source.Insert(math.MaxUint64, -1)
+	hash, _ := NewHashPartitioner(4)
+	tests := []struct {
+		name    string
+		options Options
+	}{
+		{"locked", Options{MaxLevel: 12, Shards: 4}},
+		{"lock-free", Options{MaxLevel: 12, Shards: 4, LockFree: true}},
+		{"hash", Options{MaxLevel: 12, Partitioner: hash}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skipList, err := FromSorted(tt.options, source.All())
+			if err != nil {
+				t.Fatalf("FromSorted() error %v", err)
+			}
+
+			if length := skipList.Length(); length != int32(count+1) {
+				t.Errorf("skip list's length is not correct, got %d", length)
+			}
+
+			for i := 0; i < count; i++ {
+				if node, ok := skipList.Search(uint64(i * 3)); !ok || node.Value() != i {
+					t.Fatalf("Search(%v) = %v, want %v", i*3, node, i)
+				}
+			}
+
+			// The loaded shards support all operations.
+			skipList.Insert(1, 1)
+			skipList.Delete(0)
+			if got, ok := skipList.Ceiling(0); !ok || got.Index() != 1 {
+				t.Errorf("Ceiling() = %v, want 1", got)
+			}
+
+			if got, ok := skipList.Last(); !ok || got.Index() != math.MaxUint64 {
+				t.Errorf("Last() = %v, want %v", got, uint64(math.MaxUint64))
+			}
+
+			if !skipList.loadTable().partitioner.Ordered() {
+				return
+			}
+
+			for _, position := range []int32{0, 1, 5000, int32(count)} {
+				node, ok := skipList.At(position)
+				if !ok {
+					t.Fatalf("At(%v) not found", position)
+				}
+
+				if got, ok := skipList.Rank(node.Index()); !ok || got != position {
+					t.Errorf("Rank(%v) = %v, want %v", node.Index(), got, position)
+				}
+			}
+
+			reverse := skipList.SubReverse(0, 3)
+			if len(reverse) != 3 || reverse[0].Index() != math.MaxUint64 || reverse[2].Index() != uint64((count-2)*3) {
+				t.Errorf("SubReverse() = %v", reverse)
+			}
+		})
+	}
+
+	t.Run("test invalid order", func(t *testing.T) {
+		tests := [][]uint64{{1, 1}, {2, 1}}
+		for _, indexes := range tests {
+			seq := func(yield func(uint64, interface{}) bool) {
+				for _, index := range indexes {
+					if !yield(index, index) {
+						return
+					}
+				}
+			}
+
+			if _, err := FromSorted(Options{MaxLevel: 12}, seq); err == nil {
+				t.Errorf("FromSorted(%v) should return error", indexes)
+			}
+		}
+	})
+
+	t.Run("test invalid options", func(t *testing.T) {
+		if _, err := FromSorted(Options{}, source.All()); err == nil {
+			t.Errorf("FromSorted() should return error")
+		}
+	})
+}
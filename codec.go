@@ -0,0 +1,202 @@
+package ConcurrentSkipList
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Codec encodes the values of skip list into bytes and decodes them back.
+// It's used to persist the skip list, see ConcurrentSkipList.WriteTo and Open.
+// The codec is set by Options.Codec when the skip list is created.
+type Codec interface {
+	// Encode will encode the value into bytes.
+	Encode(value interface{}) ([]byte, error)
+
+	// Decode will decode the bytes returned by Encode into the value.
+	Decode(data []byte) (interface{}, error)
+}
+
+// BytesCodec is a Codec for []byte values, the values are stored as they are.
+type BytesCodec struct{}
+
+// Encode implements Codec.
+func (BytesCodec) Encode(value interface{}) ([]byte, error) {
+	data, ok := value.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("invalid value, %T is not []byte", value)
+	}
+
+	return data, nil
+}
+
+// Decode implements Codec. The returned value doesn't share memory with data.
+func (BytesCodec) Decode(data []byte) (interface{}, error) {
+	return append([]byte{}, data...), nil
+}
+
+// StringCodec is a Codec for string values, the values are stored as their bytes.
+type StringCodec struct{}
+
+// Encode implements Codec.
+func (StringCodec) Encode(value interface{}) ([]byte, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid value, %T is not string", value)
+	}
+
+	return []byte(s), nil
+}
+
+// Decode implements Codec.
+func (StringCodec) Decode(data []byte) (interface{}, error) {
+	return string(data), nil
+}
+
+// GobCodec is a Codec encoding the values by encoding/gob. Each value is encoded as an interface value
+// with its type, so the types other than the basic types must be registered by gob.Register.
+// Each value carries its type information, so it's larger than the values encoded in one stream.
+type GobCodec struct{}
+
+// Encode implements Codec.
+func (GobCodec) Encode(value interface{}) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	if err := gob.NewEncoder(buffer).Encode(&value); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// Decode implements Codec.
+func (GobCodec) Decode(data []byte) (interface{}, error) {
+	var value interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// JSONCodec is a Codec encoding the values by encoding/json.
+// New returns a pointer to a new value which the data is decoded into, then the value it points to is returned.
+// If New is nil, the data is decoded into interface{}, so numbers are float64 and objects are map[string]interface{}.
+type JSONCodec struct {
+	New func() interface{}
+}
+
+// Encode implements Codec.
+func (c JSONCodec) Encode(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+// Decode implements Codec.
+func (c JSONCodec) Decode(data []byte) (interface{}, error) {
+	if c.New == nil {
+		var value interface{}
+		err := json.Unmarshal(data, &value)
+		return value, err
+	}
+
+	pointer := c.New()
+	if err := json.Unmarshal(data, pointer); err != nil {
+		return nil, err
+	}
+
+	return reflect.ValueOf(pointer).Elem().Interface(), nil
+}
+
+// VarintCodec is a Codec for integer values encoded as varint, the same as the int64 and sint64 of protobuf.
+// Values of all integer types are encoded, and decoded as int. Without ZigZag, a negative value costs 10 bytes
+// like int64 of protobuf. With ZigZag, small negative values are short like sint64 of protobuf.
+// Values greater than math.MaxInt64 don't fit int64, so an error is returned for them unless Unsigned is set.
+// With Unsigned, the values must not be negative and are decoded as uint64 like uint64 of protobuf.
+type VarintCodec struct {
+	ZigZag   bool
+	Unsigned bool
+}
+
+// Encode implements Codec.
+func (c VarintCodec) Encode(value interface{}) ([]byte, error) {
+	if c.ZigZag && c.Unsigned {
+		return nil, errors.New("invalid codec, unsigned varint can not be zigzag")
+	}
+
+	// u is the two's complement of negative values.
+	var u uint64
+	var negative bool
+	switch v := value.(type) {
+	case int:
+		u, negative = uint64(v), v < 0
+	case int8:
+		u, negative = uint64(v), v < 0
+	case int16:
+		u, negative = uint64(v), v < 0
+	case int32:
+		u, negative = uint64(v), v < 0
+	case int64:
+		u, negative = uint64(v), v < 0
+	case uint:
+		u = uint64(v)
+	case uint8:
+		u = uint64(v)
+	case uint16:
+		u = uint64(v)
+	case uint32:
+		u = uint64(v)
+	case uint64:
+		u = v
+	default:
+		return nil, fmt.Errorf("invalid value, %T is not integer", value)
+	}
+
+	if c.Unsigned {
+		if negative {
+			return nil, fmt.Errorf("invalid value, %v is negative", value)
+		}
+
+		return binary.AppendUvarint(nil, u), nil
+	}
+
+	if !negative && u > math.MaxInt64 {
+		return nil, fmt.Errorf("invalid value, %v overflows int64, use unsigned varint", value)
+	}
+
+	if c.ZigZag {
+		return binary.AppendVarint(nil, int64(u)), nil
+	}
+
+	return binary.AppendUvarint(nil, u), nil
+}
+
+// Decode implements Codec.
+func (c VarintCodec) Decode(data []byte) (interface{}, error) {
+	if c.ZigZag && c.Unsigned {
+		return nil, errors.New("invalid codec, unsigned varint can not be zigzag")
+	}
+
+	var x int64
+	var u uint64
+	var n int
+	if c.ZigZag {
+		x, n = binary.Varint(data)
+	} else {
+		u, n = binary.Uvarint(data)
+		x = int64(u)
+	}
+
+	if n <= 0 || n != len(data) {
+		return nil, errors.New("invalid data, data is not a varint")
+	}
+
+	if c.Unsigned {
+		return u, nil
+	}
+
+	return int(x), nil
+}
@@ -0,0 +1,109 @@
+package ConcurrentSkipList
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+)
+
+type codecPoint struct {
+	X, Y int
+}
+
+func TestCodec(t *testing.T) {
+	tests := []struct {
+		name   string
+		codec  Codec
+		values []interface{}
+		wrong  interface{}
+	}{
+		{"bytes", BytesCodec{}, []interface{}{[]byte{}, []byte("value")}, "value"},
+		{"string", StringCodec{}, []interface{}{"", "value"}, 1},
+		{"gob", GobCodec{}, []interface{}{1, "value", 1.5, []byte("value")}, make(chan int)},
+		{"json", JSONCodec{}, []interface{}{"value", 1.5, true, map[string]interface{}{"a": "b"}}, make(chan int)},
+		{"json with new", JSONCodec{New: func() interface{} { return &codecPoint{} }}, []interface{}{codecPoint{1, 2}}, make(chan int)},
+		{"varint", VarintCodec{}, []interface{}{0, 1, -1, 300, int(^uint(0) >> 1)}, "1"},
+		{"zigzag", VarintCodec{ZigZag: true}, []interface{}{0, 1, -1, 300, -300}, 1.5},
+		{"unsigned", VarintCodec{Unsigned: true}, []interface{}{uint64(0), uint64(300), uint64(math.MaxInt64), uint64(1 << 63), uint64(math.MaxUint64)}, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, value := range tt.values {
+				data, err := tt.codec.Encode(value)
+				if err != nil {
+					t.Fatalf("Encode(%v) error = %v", value, err)
+				}
+
+				got, err := tt.codec.Decode(data)
+				if err != nil || !reflect.DeepEqual(got, value) {
+					t.Errorf("Decode(Encode(%v)) = %v, %v", value, got, err)
+				}
+			}
+
+			if _, err := tt.codec.Encode(tt.wrong); err == nil {
+				t.Errorf("Encode(%T) should return an error", tt.wrong)
+			}
+		})
+	}
+
+	t.Run("test protobuf compatible", func(t *testing.T) {
+		for _, c := range []struct {
+			codec VarintCodec
+			value interface{}
+			want  []byte
+		}{
+			{VarintCodec{}, 300, []byte{0xac, 0x02}},
+			{VarintCodec{}, int64(-1), []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+			{VarintCodec{}, uint8(150), []byte{0x96, 0x01}},
+			{VarintCodec{ZigZag: true}, -1, []byte{0x01}},
+			{VarintCodec{ZigZag: true}, int32(-2), []byte{0x03}},
+		} {
+			if data, _ := c.codec.Encode(c.value); !bytes.Equal(data, c.want) {
+				t.Errorf("Encode(%v) = %x, want %x", c.value, data, c.want)
+			}
+		}
+
+		if _, err := (VarintCodec{}).Decode([]byte{0xac}); err == nil {
+			t.Errorf("Decode() should return an error for truncated varint")
+		}
+	})
+
+	t.Run("test int64 boundary", func(t *testing.T) {
+		for _, codec := range []VarintCodec{{}, {ZigZag: true}} {
+			if data, err := codec.Encode(uint64(math.MaxInt64)); err != nil {
+				t.Errorf("Encode(MaxInt64) error = %v", err)
+			} else if got, _ := codec.Decode(data); got != math.MaxInt64 {
+				t.Errorf("Decode(Encode(MaxInt64)) = %v", got)
+			}
+
+			for _, value := range []interface{}{uint64(1 << 63), uint64(math.MaxUint64), ^uint(0)} {
+				if _, err := codec.Encode(value); err == nil {
+					t.Errorf("Encode(%v) should return an error as it overflows int64", value)
+				}
+			}
+		}
+
+		if _, err := (VarintCodec{ZigZag: true, Unsigned: true}).Encode(1); err == nil {
+			t.Errorf("Encode() should return an error for unsigned zigzag")
+		}
+	})
+
+	t.Run("test skip list", func(t *testing.T) {
+		skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, Codec: StringCodec{}})
+		skipList.Insert(1, "a")
+		skipList.Insert(2, "b")
+
+		buffer := &bytes.Buffer{}
+		skipList.WriteTo(buffer)
+		restored, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, Codec: StringCodec{}})
+		if _, err := restored.ReadFrom(buffer); err != nil {
+			t.Fatal(err)
+		}
+
+		if node, ok := restored.Search(2); !ok || node.Value() != "b" {
+			t.Errorf("Search() = %v, want b", node)
+		}
+	})
+}
@@ -4,8 +4,9 @@ Package ConcurrentSkipList provide an implementation of skip list. It's thread-s
 package ConcurrentSkipList
 
 import (
-	"errors"
 	"math"
+	"math/rand"
+	"sync"
 	"sync/atomic"
 
 	"github.com/OneOfOne/xxhash"
@@ -20,24 +21,45 @@ const (
 	SHARDS      = 32
 )
 
-// shardIndex is used to indicate which shard a given index belong to.
-var shardIndexes = make([]uint64, SHARDS)
-
-// init will initialize the shardIndexes.
-func init() {
-	var step uint64 = 1 << 59 // 2^64/SHARDS
-	var t uint64 = math.MaxUint64
-
-	for i := SHARDS - 1; i >= 0; i-- {
-		shardIndexes[i] = t
-		t -= step
-	}
-}
-
 // ConcurrentSkipList is a struct contains a slice of concurrent skip list.
+// The slice and the partitioner routing indexes to its shards are stored in a routing table.
 type ConcurrentSkipList struct {
-	skipLists []*skipList
-	level     int
+	level       int
+	probability float64
+	random      *rand.Rand
+	lockFree    bool
+	table       atomic.Pointer[routingTable]
+	// rebalanceMutex serializes the replacement of routing table.
+	rebalanceMutex sync.Mutex
+	// The thresholds of splitting and merging shards, see Options.
+	splitLength     int32
+	splitContention int64
+	mergeLength     int32
+	// balancing is 1 while a background balancing is running.
+	balancing int32
+	// versions is the sequence number and the active snapshots.
+	versions versions
+	// onExpire is called with the expired nodes, see Options.OnExpire. expiring is 1 while the janitor
+	// removing expired nodes is running, janitorDeadline is the deadline it's waiting for and wake wakes it.
+	onExpire        func(index uint64, value interface{})
+	expiring        int32
+	janitorDeadline int64
+	wake            chan struct{}
+	// capacity is the maximum length, see Options.Capacity. clock is increased by each access for EvictLRU.
+	// evictMutex serializes the evictions.
+	capacity   int32
+	eviction   EvictionPolicy
+	onEvict    func(index uint64, value interface{})
+	clock      uint64
+	evictMutex sync.Mutex
+	// codec encodes and decodes the values, see Options.Codec.
+	codec Codec
+	// wal is the write-ahead log, nil means the skip list is not durable, see Open.
+	wal *wal
+	// watch is the registry of watches, see Watch.
+	watch       watchers
+	watchBuffer int
+	watchPolicy WatchPolicy
 }
 
 // NewConcurrentSkipList will create a new concurrent skip list with given level.
@@ -48,19 +70,17 @@ type ConcurrentSkipList struct {
 // For example, if you expect the skip list contains 10000000 elements, then N = 10000000, L(N) ≈ 12.
 // After initialization, the head field's level equal to level parameter and point to tail field.
 func NewConcurrentSkipList(level int) (*ConcurrentSkipList, error) {
-	if level <= 0 || level > MAX_LEVEL {
-		return nil, errors.New("invalid level, level must between 1 to 32")
-	}
-
-	skipLists := make([]*skipList, SHARDS, SHARDS)
-	for i := 0; i < SHARDS; i++ {
-		skipLists[i] = newSkipList(level)
-	}
+	return NewConcurrentSkipListWithOptions(Options{MaxLevel: level})
+}
 
-	return &ConcurrentSkipList{
-		skipLists: skipLists,
-		level:     level,
-	}, nil
+// NewLockFreeConcurrentSkipList is the same as NewConcurrentSkipList but the shards are lock-free.
+// Instead of locking the shard, the nodes are linked and unlinked by CAS on marked pointers,
+// so writers of the same shard don't block each other and readers never block.
+// In exchange, the position functions Rank, At, Sub and SubReverse walk the shard in O(n) as
+// the lock-free shard doesn't maintain spans, and the reverse functions copy the shard first as
+// it doesn't maintain backward links.
+func NewLockFreeConcurrentSkipList(level int) (*ConcurrentSkipList, error) {
+	return NewConcurrentSkipListWithOptions(Options{MaxLevel: level, LockFree: true})
 }
 
 // Level will return the level of skip list.
@@ -71,7 +91,7 @@ func (s *ConcurrentSkipList) Level() int {
 // Length will return the length of skip list.
 func (s *ConcurrentSkipList) Length() int32 {
 	var length int32
-	for _, sl := range s.skipLists {
+	for _, sl := range s.loadTable().skipLists {
 		length += sl.getLength()
 	}
 
@@ -81,34 +101,201 @@ func (s *ConcurrentSkipList) Length() int32 {
 // Search will search the skip list with the given index.
 // If the index exists, return the value and true, otherwise return nil and false.
 func (s *ConcurrentSkipList) Search(index uint64) (*Node, bool) {
-	sl := s.skipLists[getShardIndex(index)]
-	if atomic.LoadInt32(&sl.length) == 0 {
+	sl := s.shardFor(index)
+	if sl.getLength() == 0 {
 		return nil, false
 	}
 
-	result := sl.searchWithoutPreviousNodes(index)
+	result := sl.search(index)
+	if result != nil {
+		s.touch(result)
+	}
+
 	return result, result != nil
 }
 
+// Ceiling will return the node with the least index >= given index.
+// If the node exists, return the node and true, otherwise return nil and false.
+// The node may live in a shard after the given index's shard.
+// If the shards are not ordered, all shards are visited.
+func (s *ConcurrentSkipList) Ceiling(index uint64) (*Node, bool) {
+	table := s.loadTable()
+	if !table.partitioner.Ordered() {
+		return table.best(func(sl shard) *Node {
+			return sl.ceiling(index)
+		}, true)
+	}
+
+	for i := table.partitioner.Shard(index); i < len(table.skipLists); i++ {
+		sl := table.skipLists[i]
+		if sl.getLength() == 0 {
+			continue
+		}
+
+		if node := sl.ceiling(index); node != nil {
+			return node, true
+		}
+	}
+
+	return nil, false
+}
+
+// Floor will return the node with the greatest index <= given index.
+// If the node exists, return the node and true, otherwise return nil and false.
+// The node may live in a shard before the given index's shard.
+// If the shards are not ordered, all shards are visited.
+func (s *ConcurrentSkipList) Floor(index uint64) (*Node, bool) {
+	table := s.loadTable()
+	if !table.partitioner.Ordered() {
+		return table.best(func(sl shard) *Node {
+			return sl.floor(index)
+		}, false)
+	}
+
+	for i := table.partitioner.Shard(index); i >= 0; i-- {
+		sl := table.skipLists[i]
+		if sl.getLength() == 0 {
+			continue
+		}
+
+		if node := sl.floor(index); node != nil {
+			return node, true
+		}
+	}
+
+	return nil, false
+}
+
+// Higher will return the node with the least index > given index.
+// If the node exists, return the node and true, otherwise return nil and false.
+func (s *ConcurrentSkipList) Higher(index uint64) (*Node, bool) {
+	if index == math.MaxUint64 {
+		return nil, false
+	}
+
+	return s.Ceiling(index + 1)
+}
+
+// Lower will return the node with the greatest index < given index.
+// If the node exists, return the node and true, otherwise return nil and false.
+func (s *ConcurrentSkipList) Lower(index uint64) (*Node, bool) {
+	if index == 0 {
+		return nil, false
+	}
+
+	return s.Floor(index - 1)
+}
+
 // Insert will insert a value into skip list. If skip has these this index, overwrite the value, otherwise add it.
 func (s *ConcurrentSkipList) Insert(index uint64, value interface{}) {
+	s.Swap(index, value)
+}
+
+// Delete the node with the given index.
+func (s *ConcurrentSkipList) Delete(index uint64) {
+	s.LoadAndDelete(index)
+}
+
+// Swap will store the value of the index and return the previous value if any.
+// The loaded result reports whether the index was present. A nil value is ignored like Insert.
+func (s *ConcurrentSkipList) Swap(index uint64, value interface{}) (previous interface{}, loaded bool) {
 	// Ignore nil value.
 	if value == nil {
-		return
+		return nil, false
+	}
+
+	table := s.loadTable()
+	if o, ok := table.partitioner.(observer); ok {
+		o.observe(index)
 	}
 
-	sl := s.skipLists[getShardIndex(index)]
-	sl.insert(index, value)
+	sl := table.skipLists[table.partitioner.Shard(index)]
+	previous, loaded = sl.insert(index, value)
+	s.checkSplit(sl)
+	s.checkCapacity()
+	return previous, loaded
 }
 
-// Delete the node with the given index.
-func (s *ConcurrentSkipList) Delete(index uint64) {
-	sl := s.skipLists[getShardIndex(index)]
-	if atomic.LoadInt32(&sl.length) == 0 {
-		return
+// LoadAndDelete will delete the index and return its previous value if any.
+// The loaded result reports whether the index was present.
+func (s *ConcurrentSkipList) LoadAndDelete(index uint64) (value interface{}, loaded bool) {
+	sl := s.shardFor(index)
+	if sl.getLength() == 0 {
+		return nil, false
+	}
+
+	value, loaded = sl.delete(index)
+	s.checkMerge(sl)
+	return value, loaded
+}
+
+// LoadOrStore will return the existing value of the index if present, otherwise store and return given value.
+// The loaded result is true if the value was loaded, false if stored. A nil value is not stored.
+func (s *ConcurrentSkipList) LoadOrStore(index uint64, value interface{}) (actual interface{}, loaded bool) {
+	actual, _ = s.Compute(index, func(old interface{}, exists bool) (interface{}, bool) {
+		loaded = exists
+		if exists {
+			return old, true
+		}
+
+		return value, true
+	})
+
+	return actual, loaded
+}
+
+// CompareAndSwap will swap the old and new values of the index if the value stored is equal to old.
+// The values are compared by ==, so the old value must be comparable. A nil new value is not stored.
+func (s *ConcurrentSkipList) CompareAndSwap(index uint64, old, new interface{}) (swapped bool) {
+	if new == nil {
+		return false
+	}
+
+	s.Compute(index, func(value interface{}, exists bool) (interface{}, bool) {
+		swapped = exists && value == old
+		if swapped {
+			return new, true
+		}
+
+		return value, exists
+	})
+
+	return swapped
+}
+
+// CompareAndDelete will delete the index if its value is equal to old.
+// The values are compared by ==, so the old value must be comparable.
+func (s *ConcurrentSkipList) CompareAndDelete(index uint64, old interface{}) (deleted bool) {
+	s.Compute(index, func(value interface{}, exists bool) (interface{}, bool) {
+		deleted = exists && value == old
+		return value, exists && !deleted
+	})
+
+	return deleted
+}
+
+// Compute will call f() with the current value of the index and whether it exists, then store the returned
+// value if keep is true, otherwise delete the index. A nil value is not stored, the same as keep is false.
+// Return the value after computing and whether it exists.
+// The read and the write are atomic. f() is called under the write lock of the shard, so it must not access
+// the skip list. For lock-free shards, the value is replaced by CAS and f() is called again if the value
+// is changed meanwhile, so f() may be called more than once.
+func (s *ConcurrentSkipList) Compute(index uint64, f func(old interface{}, exists bool) (value interface{}, keep bool)) (interface{}, bool) {
+	table := s.loadTable()
+	sl := table.skipLists[table.partitioner.Shard(index)]
+	value, ok := sl.compute(index, f)
+	if !ok {
+		s.checkMerge(sl)
+		return nil, false
+	}
+
+	if o, ok := table.partitioner.(observer); ok {
+		o.observe(index)
 	}
 
-	sl.delete(index)
+	s.checkSplit(sl)
+	s.checkCapacity()
+	return value, true
 }
 
 // ForEach will create a snapshot first shard by shard. Then iterate each node in snapshot and do the function f().
@@ -116,7 +303,7 @@ func (s *ConcurrentSkipList) Delete(index uint64) {
 // If skip list is inserted or deleted while iterating, the node in snapshot will not change.
 // The performance is not very high and the snapshot with be stored in memory.
 func (s *ConcurrentSkipList) ForEach(f func(node *Node) bool) {
-	for _, sl := range s.skipLists {
+	for _, sl := range s.loadTable().skipLists {
 		if sl.getLength() == 0 {
 			continue
 		}
@@ -136,8 +323,150 @@ func (s *ConcurrentSkipList) ForEach(f func(node *Node) bool) {
 	}
 }
 
+// Range will iterate the nodes whose index is in [lo, hi) in ascending order and do the function f().
+// If f() return false, stop iterating and return.
+// Only the shards overlapping [lo, hi) are visited and each of them is seeked to lo directly,
+// so the cost depends on the count of nodes in range instead of the length of skip list.
+// Like ForEach, the nodes of a shard are copied before calling f().
+// If the shards are not ordered, all shards are visited and the nodes are only ordered in each shard.
+func (s *ConcurrentSkipList) Range(lo, hi uint64, f func(node *Node) bool) {
+	// Ignore empty range.
+	if lo >= hi {
+		return
+	}
+
+	table := s.loadTable()
+	first, last := table.shardRange(lo, hi)
+	for i := first; i <= last; i++ {
+		sl := table.skipLists[i]
+		if sl.getLength() == 0 {
+			continue
+		}
+
+		for _, node := range sl.snapshotRange(lo, hi) {
+			if !f(node) {
+				return
+			}
+		}
+	}
+}
+
+// First will return the node with the least index.
+// If skip list is empty, return nil and false.
+func (s *ConcurrentSkipList) First() (*Node, bool) {
+	table := s.loadTable()
+	if !table.partitioner.Ordered() {
+		return table.best(shard.first, true)
+	}
+
+	for _, sl := range table.skipLists {
+		if sl.getLength() == 0 {
+			continue
+		}
+
+		if node := sl.first(); node != nil {
+			return node, true
+		}
+	}
+
+	return nil, false
+}
+
+// Last will return the node with the greatest index.
+// If skip list is empty, return nil and false.
+func (s *ConcurrentSkipList) Last() (*Node, bool) {
+	table := s.loadTable()
+	if !table.partitioner.Ordered() {
+		return table.best(shard.last, false)
+	}
+
+	for i := len(table.skipLists) - 1; i >= 0; i-- {
+		sl := table.skipLists[i]
+		if sl.getLength() == 0 {
+			continue
+		}
+
+		if node := sl.last(); node != nil {
+			return node, true
+		}
+	}
+
+	return nil, false
+}
+
+// PopFirst will remove the node with the least index and return it.
+// The node is found and removed under the same shard lock, so concurrent callers never get the same node.
+// If skip list is empty, return nil and false.
+// If the shards are not ordered, the shard with the least index is found first, then its first node is removed.
+func (s *ConcurrentSkipList) PopFirst() (*Node, bool) {
+	return s.pop(shard.first, shard.popFirst, true)
+}
+
+// PopLast will remove the node with the greatest index and return it.
+// The node is found and removed under the same shard lock, so concurrent callers never get the same node.
+// If skip list is empty, return nil and false.
+// If the shards are not ordered, the shard with the greatest index is found first, then its last node is removed.
+func (s *ConcurrentSkipList) PopLast() (*Node, bool) {
+	return s.pop(shard.last, shard.popLast, false)
+}
+
+// pop will remove a node by popFirst or popLast. ascending indicates which end to pop.
+// A retired shard pops nothing, so if the routing table is replaced meanwhile, retry with the new one.
+func (s *ConcurrentSkipList) pop(peek, pop func(shard) *Node, ascending bool) (*Node, bool) {
+retry:
+	for {
+		table := s.loadTable()
+		if !table.partitioner.Ordered() {
+			// Find the shard whose first or last node is the best.
+			var candidate shard
+			var best *Node
+			for _, sl := range table.skipLists {
+				if sl.getLength() == 0 {
+					continue
+				}
+
+				if node := peek(sl); node != nil && (best == nil || (node.index < best.index) == ascending) {
+					candidate, best = sl, node
+				}
+			}
+
+			if candidate == nil {
+				return nil, false
+			}
+
+			if node := pop(candidate); node != nil {
+				return node, true
+			}
+
+			continue
+		}
+
+		for i := range table.skipLists {
+			sl := table.skipLists[i]
+			if !ascending {
+				sl = table.skipLists[len(table.skipLists)-1-i]
+			}
+
+			if sl.getLength() == 0 {
+				continue
+			}
+
+			if node := pop(sl); node != nil {
+				return node, true
+			}
+
+			if s.loadTable() != table {
+				continue retry
+			}
+		}
+
+		return nil, false
+	}
+}
+
 // Sub will return a slice the skip list who starts with startNumber.
 // The startNumber start with 0 as same as slice and maximum length is skip list's length.
+// The shard containing startNumber is seeked by spans in O(log n), so only the returned nodes are copied.
 func (s *ConcurrentSkipList) Sub(startNumber int32, length int32) []*Node {
 	// Ignore invalid parameter.
 	if startNumber > s.Length() || startNumber < 0 || length <= 0 {
@@ -145,49 +474,150 @@ func (s *ConcurrentSkipList) Sub(startNumber int32, length int32) []*Node {
 	}
 
 	var result []*Node
-	var position, count int32 = 0, 0
-	for _, sl := range s.skipLists {
+	var position int32
+	for _, sl := range s.loadTable().skipLists {
+		if int32(len(result)) == length {
+			break
+		}
+
 		if l := sl.getLength(); l == 0 || position+l <= startNumber {
 			position += l
 			continue
 		}
 
-		nodes := sl.snapshot()
-		for _, node := range nodes {
-			if position < startNumber {
-				position++
-				continue
-			}
+		var skip int32
+		if position < startNumber {
+			skip = startNumber - position
+		}
 
-			if count == length {
-				break
-			}
+		nodes := sl.sub(skip, length-int32(len(result)))
+		position += skip + int32(len(nodes))
+		result = append(result, nodes...)
+	}
+
+	return result
+}
 
-			result = append(result, node)
-			count++
+// Rank will return the position of the node with given index in the whole skip list.
+// The position starts with 0 as same as Sub. If the index doesn't exist, return -1 and false.
+// The position is computed in O(log n) by spans within the shard plus the length of previous shards.
+func (s *ConcurrentSkipList) Rank(index uint64) (int32, bool) {
+	table := s.loadTable()
+	shard := table.partitioner.Shard(index)
+	sl := table.skipLists[shard]
+	if sl.getLength() == 0 {
+		return -1, false
+	}
+
+	rank := sl.rank(index)
+	if rank < 0 {
+		return -1, false
+	}
+
+	for i := 0; i < shard; i++ {
+		rank += table.skipLists[i].getLength()
+	}
+
+	return rank, true
+}
+
+// At will return the node at the given position of the whole skip list.
+// The position starts with 0 as same as Sub. If position is out of range, return nil and false.
+func (s *ConcurrentSkipList) At(position int32) (*Node, bool) {
+	if position < 0 {
+		return nil, false
+	}
+
+	for _, sl := range s.loadTable().skipLists {
+		l := sl.getLength()
+		if position >= l {
+			position -= l
+			continue
 		}
 
-		if count == length {
-			break
+		if node := sl.at(position); node != nil {
+			return node, true
 		}
+
+		break
 	}
 
-	return result
+	return nil, false
 }
 
-// Locate which shard the given index belong to.
-func getShardIndex(index uint64) int {
-	result := -1
-	for i, t := range shardIndexes {
-		if index <= t {
-			result = i
-			break
+// ForEachReverse is the same as ForEach but iterates the nodes in descending order.
+// It starts from the last shard and follows the backward links of each shard.
+func (s *ConcurrentSkipList) ForEachReverse(f func(node *Node) bool) {
+	skipLists := s.loadTable().skipLists
+	for i := len(skipLists) - 1; i >= 0; i-- {
+		sl := skipLists[i]
+		if sl.getLength() == 0 {
+			continue
+		}
+
+		for _, node := range sl.snapshotReverse() {
+			if !f(node) {
+				return
+			}
 		}
 	}
+}
+
+// SubReverse is the same as Sub but counts from the end of skip list and returns the nodes in descending order.
+// For example, SubReverse(0, 10) returns the 10 nodes with the largest indexes.
+// Only the visited nodes are copied, the shards before them are not snapshotted.
+func (s *ConcurrentSkipList) SubReverse(startNumber int32, length int32) []*Node {
+	// Ignore invalid parameter.
+	if startNumber > s.Length() || startNumber < 0 || length <= 0 {
+		return nil
+	}
+
+	var result []*Node
+	var position int32
+	skipLists := s.loadTable().skipLists
+	for i := len(skipLists) - 1; i >= 0 && int32(len(result)) < length; i-- {
+		sl := skipLists[i]
+		if l := sl.getLength(); l == 0 || position+l <= startNumber {
+			position += l
+			continue
+		}
+
+		var skip int32
+		if position < startNumber {
+			skip = startNumber - position
+		}
+
+		nodes := sl.subReverse(skip, length-int32(len(result)))
+		position += skip + int32(len(nodes))
+		result = append(result, nodes...)
+	}
 
 	return result
 }
 
+// RangeReverse is the same as Range but iterates the nodes in descending order.
+func (s *ConcurrentSkipList) RangeReverse(lo, hi uint64, f func(node *Node) bool) {
+	// Ignore empty range.
+	if lo >= hi {
+		return
+	}
+
+	table := s.loadTable()
+	first, last := table.shardRange(lo, hi)
+	for i := last; i >= first; i-- {
+		sl := table.skipLists[i]
+		if sl.getLength() == 0 {
+			continue
+		}
+
+		for _, node := range sl.snapshotRangeReverse(lo, hi) {
+			if !f(node) {
+				return
+			}
+		}
+	}
+}
+
 // Hash will calculate the input's hash value using xxHash algorithm.
 // It can be used to calculate the index of skip list.
 // See more detail in https://cyan4973.github.io/xxHash/
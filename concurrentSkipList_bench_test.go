@@ -25,6 +25,19 @@ func BenchmarkConcurrentSkipList_Insert_Randomly(b *testing.B) {
 		skipList.Insert(index, index)
 	}
 }
+
+func BenchmarkConcurrentSkipList_InsertBatch_Ordered(b *testing.B) {
+	skipList, _ := NewConcurrentSkipList(12)
+	entries := make([]Entry, b.N)
+	for i := range entries {
+		entries[i] = Entry{Index: uint64(i), Value: i}
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	skipList.InsertBatch(entries)
+}
+
 func BenchmarkConcurrentSkipList_Delete(b *testing.B) {
 	skipList, _ := NewConcurrentSkipList(12)
 	for i := 0; i < 10000000; i++ {
@@ -210,6 +223,73 @@ func BenchmarkConcurrentSkipList_Search_Parallel(b *testing.B) {
 	})
 }
 
+func BenchmarkLockFreeConcurrentSkipList_Insert_Parallel(b *testing.B) {
+	skipList, _ := NewLockFreeConcurrentSkipList(12)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			skipList.Insert(uint64(rand.Intn(b.N)), 0)
+		}
+	})
+}
+
+func BenchmarkLockFreeConcurrentSkipList_Search_Parallel(b *testing.B) {
+	skipList, _ := NewLockFreeConcurrentSkipList(12)
+	go func() {
+		var wg sync.WaitGroup
+		for i := 0; i < b.N; i++ {
+			t := rand.Intn(b.N)
+			index := Hash([]byte(strconv.Itoa(t)))
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				skipList.Insert(index, index)
+			}()
+		}
+
+		wg.Wait()
+	}()
+
+	go func() {
+		var wg sync.WaitGroup
+		for i := 0; i < b.N; i++ {
+			t := rand.Intn(b.N)
+			index := Hash([]byte(strconv.Itoa(t)))
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				skipList.Delete(index)
+			}()
+		}
+
+		wg.Wait()
+	}()
+
+	time.Sleep(time.Millisecond)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			skipList.Search(uint64(rand.Intn(b.N)))
+		}
+	})
+}
+
+func BenchmarkConcurrentSkipList_Sub_1000000Elements(b *testing.B) {
+	skipList, _ := NewConcurrentSkipList(12)
+	for i := 0; i < 1000000; i++ {
+		skipList.Insert(Hash([]byte(strconv.Itoa(i))), i)
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		skipList.Sub(int32(rand.Intn(1000000)), 20)
+	}
+}
+
 func BenchmarkHash(b *testing.B) {
 	b.ResetTimer()
 	b.ReportAllocs()
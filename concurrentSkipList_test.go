@@ -6,10 +6,15 @@ import (
 	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
+// shardIndexes is the default shard layout used by the tests to pick indexes of given shards.
+// Each element is the maximum index of a shard.
+var shardIndexes = newShardIndexes(SHARDS)
+
 func TestNewConcurrentSkipList(t *testing.T) {
 	type args struct {
 		level int
@@ -509,15 +514,16 @@ func TestConcurrentSkipList_ForEach_Parallel(t *testing.T) {
 }
 
 func TestConcurrentSkipList_Level(t *testing.T) {
-	skipList, _ := NewConcurrentSkipList(16)
+	concurrentSkipList, _ := NewConcurrentSkipList(16)
 	for i := 0; i < 100000; i++ {
 		index := Hash([]byte(strconv.Itoa(i)))
-		skipList.Insert(index, i)
+		concurrentSkipList.Insert(index, i)
 	}
 
-	length := skipList.Length()
+	length := concurrentSkipList.Length()
 	levels := make([]int, 17)
-	for _, sl := range skipList.skipLists {
+	for _, sh := range concurrentSkipList.loadTable().skipLists {
+		sl := sh.(*skipList)
 		if sl.getLength() == 0 {
 			continue
 		}
@@ -591,6 +597,567 @@ func TestConcurrentSkipList_Sub(t *testing.T) {
 	}
 }
 
+func TestConcurrentSkipList_Range(t *testing.T) {
+	skipList, _ := NewConcurrentSkipList(12)
+	for i := 0; i < 100; i++ {
+		skipList.Insert(uint64(i), i)
+	}
+
+	// Put indexes into the last shards.
+	for _, v := range shardIndexes[SHARDS-3:] {
+		skipList.Insert(v, v)
+		skipList.Insert(v-1, v-1)
+	}
+
+	type args struct {
+		lo uint64
+		hi uint64
+	}
+	tests := []struct {
+		name  string
+		args  args
+		want  int
+		first uint64
+	}{
+		{"test1", args{10, 10}, 0, 0},
+		{"test2", args{20, 10}, 0, 0},
+		{"test3", args{10, 20}, 10, 10},
+		{"test4", args{90, 1 << 60}, 10, 90},
+		{"test5", args{100, shardIndexes[SHARDS-3]}, 1, shardIndexes[SHARDS-3] - 1},
+		{"test6", args{shardIndexes[SHARDS-3], math.MaxUint64}, 4, shardIndexes[SHARDS-3]},
+		{"test7", args{0, math.MaxUint64}, 105, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []*Node
+			skipList.Range(tt.args.lo, tt.args.hi, func(node *Node) bool {
+				got = append(got, node)
+				return true
+			})
+
+			if len(got) != tt.want {
+				t.Fatalf("Range() count = %v, want = %v", len(got), tt.want)
+			}
+
+			for i, node := range got {
+				if node.Index() < tt.args.lo || node.Index() >= tt.args.hi || (i > 0 && got[i-1].Index() >= node.Index()) {
+					t.Errorf("Range() got incorrect index %v", node.Index())
+				}
+			}
+
+			if len(got) > 0 && got[0].Index() != tt.first {
+				t.Errorf("Range() first = %v, want = %v", got[0].Index(), tt.first)
+			}
+		})
+	}
+
+	t.Run("test stop", func(t *testing.T) {
+		count := 0
+		skipList.Range(0, math.MaxUint64, func(node *Node) bool {
+			count++
+			return count < 3
+		})
+
+		if count != 3 {
+			t.Errorf("Range() count = %v, want = %v", count, 3)
+		}
+	})
+}
+
+func TestConcurrentSkipList_Reverse(t *testing.T) {
+	skipList, _ := NewConcurrentSkipList(12)
+	count := 1000
+	indexes := make([]uint64, 0, count)
+	for i := 0; i < count; i++ {
+		index := Hash([]byte(strconv.Itoa(i)))
+		indexes = append(indexes, index)
+		skipList.Insert(index, i)
+	}
+
+	// Delete some nodes to check the backward links are maintained.
+	for i := 0; i < count; i += 3 {
+		skipList.Delete(indexes[i])
+	}
+
+	var want []uint64
+	skipList.ForEach(func(node *Node) bool {
+		want = append([]uint64{node.Index()}, want...)
+		return true
+	})
+
+	t.Run("test ForEachReverse", func(t *testing.T) {
+		var got []uint64
+		skipList.ForEachReverse(func(node *Node) bool {
+			got = append(got, node.Index())
+			return true
+		})
+
+		if len(got) != len(want) {
+			t.Fatalf("ForEachReverse() count = %v, want = %v", len(got), len(want))
+		}
+
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("ForEachReverse() = %v, want = %v", got[i], want[i])
+			}
+		}
+	})
+
+	type args struct {
+		startNumber int32
+		length      int32
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{"test1", args{0, -1}, 0},
+		{"test2", args{-1, 1}, 0},
+		{"test3", args{int32(len(want)) + 1, 1}, 0},
+		{"test4", args{0, 10}, 10},
+		{"test5", args{100, 300}, 300},
+		{"test6", args{int32(len(want)) - 5, 10}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := skipList.SubReverse(tt.args.startNumber, tt.args.length)
+			if len(got) != tt.want {
+				t.Fatalf("SubReverse() count = %v, want = %v", len(got), tt.want)
+			}
+
+			for i, node := range got {
+				if node.Index() != want[int(tt.args.startNumber)+i] {
+					t.Fatalf("SubReverse() = %v, want = %v", node.Index(), want[int(tt.args.startNumber)+i])
+				}
+			}
+		})
+	}
+
+	t.Run("test RangeReverse", func(t *testing.T) {
+		lo, hi := want[len(want)-100], want[99]
+		var got []uint64
+		skipList.RangeReverse(lo, hi, func(node *Node) bool {
+			got = append(got, node.Index())
+			return true
+		})
+
+		if len(got) != len(want)-199 {
+			t.Fatalf("RangeReverse() count = %v, want = %v", len(got), len(want)-199)
+		}
+
+		for i := range got {
+			if got[i] != want[100+i] {
+				t.Fatalf("RangeReverse() = %v, want = %v", got[i], want[100+i])
+			}
+		}
+	})
+}
+
+func TestConcurrentSkipList_Navigation(t *testing.T) {
+	skipList, _ := NewConcurrentSkipList(12)
+	type want struct {
+		existed bool
+		index   uint64
+	}
+
+	t.Run("test empty", func(t *testing.T) {
+		if _, ok := skipList.Ceiling(0); ok {
+			t.Errorf("Ceiling() on empty skip list should not exist")
+		}
+
+		if _, ok := skipList.Floor(math.MaxUint64); ok {
+			t.Errorf("Floor() on empty skip list should not exist")
+		}
+	})
+
+	// 10 and 20 are in shard 0, 1<<60 is in shard 2 and math.MaxUint64 is in the last shard.
+	for _, index := range []uint64{10, 20, 1 << 60, math.MaxUint64} {
+		skipList.Insert(index, index)
+	}
+
+	tests := []struct {
+		name  string
+		f     func(uint64) (*Node, bool)
+		index uint64
+		want  want
+	}{
+		{"test ceiling1", skipList.Ceiling, 0, want{true, 10}},
+		{"test ceiling2", skipList.Ceiling, 10, want{true, 10}},
+		{"test ceiling3", skipList.Ceiling, 21, want{true, 1 << 60}},
+		{"test ceiling4", skipList.Ceiling, 1<<60 + 1, want{true, math.MaxUint64}},
+		{"test floor1", skipList.Floor, 9, want{false, 0}},
+		{"test floor2", skipList.Floor, 15, want{true, 10}},
+		{"test floor3", skipList.Floor, 1<<60 - 1, want{true, 20}},
+		{"test floor4", skipList.Floor, math.MaxUint64, want{true, math.MaxUint64}},
+		{"test floor5", skipList.Floor, math.MaxUint64 - 1, want{true, 1 << 60}},
+		{"test higher1", skipList.Higher, 10, want{true, 20}},
+		{"test higher2", skipList.Higher, 20, want{true, 1 << 60}},
+		{"test higher3", skipList.Higher, math.MaxUint64, want{false, 0}},
+		{"test lower1", skipList.Lower, 0, want{false, 0}},
+		{"test lower2", skipList.Lower, 10, want{false, 0}},
+		{"test lower3", skipList.Lower, 1 << 60, want{true, 20}},
+		{"test lower4", skipList.Lower, math.MaxUint64, want{true, 1 << 60}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.f(tt.index)
+			if ok != tt.want.existed || (ok && got.Index() != tt.want.index) {
+				t.Errorf("got = %v existed:%v, want = %v", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestConcurrentSkipList_Pop(t *testing.T) {
+	skipList, _ := NewConcurrentSkipList(12)
+	t.Run("test empty", func(t *testing.T) {
+		if _, ok := skipList.First(); ok {
+			t.Errorf("First() on empty skip list should not exist")
+		}
+
+		if _, ok := skipList.PopLast(); ok {
+			t.Errorf("PopLast() on empty skip list should not exist")
+		}
+	})
+
+	indexes := make([]uint64, 0)
+	for i := 0; i < 100; i++ {
+		index := Hash([]byte(strconv.Itoa(i)))
+		indexes = append(indexes, index)
+		skipList.Insert(index, i)
+	}
+
+	sort.Slice(indexes, func(i, j int) bool {
+		return indexes[i] < indexes[j]
+	})
+
+	t.Run("test First and Last", func(t *testing.T) {
+		if got, ok := skipList.First(); !ok || got.Index() != indexes[0] {
+			t.Errorf("First() = %v, want = %v", got, indexes[0])
+		}
+
+		if got, ok := skipList.Last(); !ok || got.Index() != indexes[99] {
+			t.Errorf("Last() = %v, want = %v", got, indexes[99])
+		}
+	})
+
+	t.Run("test PopFirst and PopLast", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			if got, ok := skipList.PopFirst(); !ok || got.Index() != indexes[i] {
+				t.Errorf("PopFirst() = %v, want = %v", got, indexes[i])
+			}
+
+			if got, ok := skipList.PopLast(); !ok || got.Index() != indexes[99-i] {
+				t.Errorf("PopLast() = %v, want = %v", got, indexes[99-i])
+			}
+		}
+
+		if length := skipList.Length(); length != 80 {
+			t.Errorf("skip list's length is not correct, got %d", length)
+		}
+
+		if got := skipList.SubReverse(0, 1); len(got) != 1 || got[0].Index() != indexes[89] {
+			t.Errorf("SubReverse() = %v, want = %v", got, indexes[89])
+		}
+	})
+
+	t.Run("test parallel", func(t *testing.T) {
+		var wg sync.WaitGroup
+		var mutex sync.Mutex
+		popped := make(map[uint64]bool)
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				pop := skipList.PopFirst
+				if i%2 == 0 {
+					pop = skipList.PopLast
+				}
+
+				if node, ok := pop(); ok {
+					mutex.Lock()
+					defer mutex.Unlock()
+					if popped[node.Index()] {
+						t.Errorf("node %v is popped twice", node.Index())
+					}
+
+					popped[node.Index()] = true
+				}
+			}(i)
+		}
+
+		wg.Wait()
+		if len(popped) != 80 || skipList.Length() != 0 {
+			t.Errorf("popped %d nodes, length %d", len(popped), skipList.Length())
+		}
+	})
+
+	t.Run("test overwrite", func(t *testing.T) {
+		skipList.Insert(5, 0)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 1; i <= 1000; i++ {
+				skipList.Insert(5, i)
+			}
+		}()
+
+		// The nodes are copies, their values don't change with the writes.
+		for i := 0; i < 1000; i++ {
+			first, _ := skipList.First()
+			last, _ := skipList.Last()
+			value := first.Value()
+			if first.Value() != value || last.Value() == nil {
+				t.Fatalf("First() changes from %v to %v", value, first.Value())
+			}
+		}
+
+		<-done
+	})
+}
+
+func TestConcurrentSkipList_Rank(t *testing.T) {
+	skipList, _ := NewConcurrentSkipList(12)
+	count := 2000
+	indexes := make([]uint64, 0, count)
+	for i := 0; i < count; i++ {
+		index := Hash([]byte(strconv.Itoa(i)))
+		indexes = append(indexes, index)
+		skipList.Insert(index, i)
+	}
+
+	// Delete and pop some nodes to check the spans are maintained.
+	for i := 0; i < count; i += 4 {
+		skipList.Delete(indexes[i])
+	}
+	skipList.PopFirst()
+	skipList.PopLast()
+
+	var want []uint64
+	skipList.ForEach(func(node *Node) bool {
+		want = append(want, node.Index())
+		return true
+	})
+
+	t.Run("test Rank and At", func(t *testing.T) {
+		for i, index := range want {
+			if got, ok := skipList.Rank(index); !ok || got != int32(i) {
+				t.Fatalf("Rank(%v) = %v, want = %v", index, got, i)
+			}
+
+			if got, ok := skipList.At(int32(i)); !ok || got.Index() != index {
+				t.Fatalf("At(%v) = %v, want = %v", i, got, index)
+			}
+		}
+	})
+
+	tests := []struct {
+		name     string
+		position int32
+		index    uint64
+	}{
+		{"test1", -1, 0},
+		{"test2", int32(len(want)), 0},
+		{"test3", int32(count), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, ok := skipList.At(tt.position); ok || got != nil {
+				t.Errorf("At() = %v, want = %v", got, nil)
+			}
+		})
+	}
+
+	t.Run("test overwrite", func(t *testing.T) {
+		stop, done := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+					skipList.Insert(want[0], i)
+				}
+			}
+		}()
+
+		// The node is a copy, its value doesn't change with the writes.
+		for i := 0; i < 10000; i++ {
+			node, _ := skipList.At(0)
+			if value := node.Value(); node.Value() != value {
+				t.Fatalf("At() changes from %v to %v", value, node.Value())
+			}
+		}
+
+		close(stop)
+		<-done
+	})
+
+	t.Run("test missing", func(t *testing.T) {
+		if got, ok := skipList.Rank(indexes[0]); ok || got != -1 {
+			t.Errorf("Rank() = %v, want = %v", got, -1)
+		}
+	})
+
+	t.Run("test Sub", func(t *testing.T) {
+		got := skipList.Sub(700, 300)
+		if len(got) != 300 {
+			t.Fatalf("Sub() count = %v, want = %v", len(got), 300)
+		}
+
+		for i, node := range got {
+			if node.Index() != want[700+i] {
+				t.Fatalf("Sub() = %v, want = %v", node.Index(), want[700+i])
+			}
+		}
+	})
+}
+
+func TestConcurrentSkipList_Compute(t *testing.T) {
+	newList := map[string]func(int) (*ConcurrentSkipList, error){
+		"locked":    NewConcurrentSkipList,
+		"lock-free": NewLockFreeConcurrentSkipList,
+	}
+	for name, f := range newList {
+		t.Run(name, func(t *testing.T) {
+			skipList, _ := f(12)
+
+			t.Run("test LoadOrStore", func(t *testing.T) {
+				if actual, loaded := skipList.LoadOrStore(1, "a"); loaded || actual != "a" {
+					t.Errorf("LoadOrStore() = %v, %v, want a, false", actual, loaded)
+				}
+
+				if actual, loaded := skipList.LoadOrStore(1, "b"); !loaded || actual != "a" {
+					t.Errorf("LoadOrStore() = %v, %v, want a, true", actual, loaded)
+				}
+
+				if actual, loaded := skipList.LoadOrStore(2, nil); loaded || actual != nil || skipList.Length() != 1 {
+					t.Errorf("LoadOrStore() = %v, %v, nil value should not be stored", actual, loaded)
+				}
+			})
+
+			t.Run("test CompareAndSwap", func(t *testing.T) {
+				if skipList.CompareAndSwap(1, "b", "c") || skipList.CompareAndSwap(2, nil, "c") {
+					t.Errorf("CompareAndSwap() should fail")
+				}
+
+				if !skipList.CompareAndSwap(1, "a", "c") {
+					t.Errorf("CompareAndSwap() should succeed")
+				}
+
+				if node, ok := skipList.Search(1); !ok || node.Value() != "c" {
+					t.Errorf("Search() = %v, want c", node)
+				}
+			})
+
+			t.Run("test CompareAndDelete", func(t *testing.T) {
+				if skipList.CompareAndDelete(1, "a") || skipList.CompareAndDelete(2, "c") {
+					t.Errorf("CompareAndDelete() should fail")
+				}
+
+				if !skipList.CompareAndDelete(1, "c") || skipList.Length() != 0 {
+					t.Errorf("CompareAndDelete() should succeed")
+				}
+			})
+
+			t.Run("test Compute", func(t *testing.T) {
+				increase := func(old interface{}, exists bool) (interface{}, bool) {
+					if !exists {
+						return 1, true
+					}
+
+					return old.(int) + 1, true
+				}
+
+				// Increase the counters concurrently.
+				var wg sync.WaitGroup
+				for i := 0; i < 8; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						for j := 0; j < 1000; j++ {
+							skipList.Compute(uint64(j%10), increase)
+						}
+					}()
+				}
+
+				wg.Wait()
+				for i := 0; i < 10; i++ {
+					if node, ok := skipList.Search(uint64(i)); !ok || node.Value() != 800 {
+						t.Errorf("counter %d = %v, want 800", i, node)
+					}
+				}
+
+				if value, ok := skipList.Compute(0, func(old interface{}, exists bool) (interface{}, bool) {
+					return nil, false
+				}); ok || value != nil || skipList.Length() != 9 {
+					t.Errorf("Compute() should delete the index")
+				}
+			})
+		})
+	}
+}
+
+func TestConcurrentSkipList_Swap(t *testing.T) {
+	newList := map[string]func(int) (*ConcurrentSkipList, error){
+		"locked":    NewConcurrentSkipList,
+		"lock-free": NewLockFreeConcurrentSkipList,
+	}
+	for name, f := range newList {
+		t.Run(name, func(t *testing.T) {
+			skipList, _ := f(12)
+			tests := []struct {
+				name       string
+				f          func() (interface{}, bool)
+				wantValue  interface{}
+				wantLoaded bool
+			}{
+				{"test swap new", func() (interface{}, bool) { return skipList.Swap(1, "a") }, nil, false},
+				{"test swap existed", func() (interface{}, bool) { return skipList.Swap(1, "b") }, "a", true},
+				{"test swap nil", func() (interface{}, bool) { return skipList.Swap(1, nil) }, nil, false},
+				{"test delete existed", func() (interface{}, bool) { return skipList.LoadAndDelete(1) }, "b", true},
+				{"test delete missing", func() (interface{}, bool) { return skipList.LoadAndDelete(1) }, nil, false},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					if value, loaded := tt.f(); value != tt.wantValue || loaded != tt.wantLoaded {
+						t.Errorf("got %v, %v, want %v, %v", value, loaded, tt.wantValue, tt.wantLoaded)
+					}
+				})
+			}
+
+			// Only one of the concurrent deleters gets the value.
+			t.Run("test delete parallel", func(t *testing.T) {
+				count := 1000
+				for i := 0; i < count; i++ {
+					skipList.Insert(uint64(i), i)
+				}
+
+				var wg sync.WaitGroup
+				var loadedCount int64
+				for i := 0; i < 4; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						for j := 0; j < count; j++ {
+							if _, loaded := skipList.LoadAndDelete(uint64(j)); loaded {
+								atomic.AddInt64(&loadedCount, 1)
+							}
+						}
+					}()
+				}
+
+				wg.Wait()
+				if loadedCount != int64(count) || skipList.Length() != 0 {
+					t.Errorf("loaded %d times, want %d", loadedCount, count)
+				}
+			})
+		})
+	}
+}
+
 func TestHash(t *testing.T) {
 	input := `Lorem ipsum dolor sit amet, consectetur adipisicing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua. Ut enim ad minim veniam, quis nostrud exercitation ullamco laboris nisi ut aliquip ex ea commodo consequat. Duis aute irure dolor in reprehenderit in voluptate velit esse cillum dolore eu fugiat nulla pariatur. Excepteur sint occaecat cupidatat non proident, sunt in culpa qui officia deserunt mollit anim id est laborum.
 Lorem ipsum dolor sit amet, consectetur adipisicing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua. Ut enim ad minim veniam, quis nostrud exercitation ullamco laboris nisi ut aliquip ex ea commodo consequat. Duis aute irure dolor in reprehenderit in voluptate velit esse cillum dolore eu fugiat nulla pariatur. Excepteur sint occaecat cupidatat non proident, sunt in culpa qui officia deserunt mollit anim id est laborum.
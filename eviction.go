@@ -0,0 +1,163 @@
+package ConcurrentSkipList
+
+import (
+	"math/rand"
+	"sync/atomic"
+)
+
+// EvictionPolicy chooses the node to evict when the length of skip list is beyond Options.Capacity.
+type EvictionPolicy int
+
+const (
+	// EvictLowest evicts the node with the least index.
+	EvictLowest EvictionPolicy = iota
+	// EvictHighest evicts the node with the greatest index.
+	EvictHighest
+	// EvictLRU evicts the least recently inserted, updated or searched node.
+	EvictLRU
+	// EvictLFU evicts the least frequently inserted, updated or searched node.
+	EvictLFU
+)
+
+// evictionSamples is the count of nodes sampled to find the node to evict by EvictLRU or EvictLFU.
+// Like redis, the nodes are sampled instead of being ordered by access, so accessing a node doesn't
+// need a lock and the evicted node is approximately the least recently or frequently used one.
+const evictionSamples = 5
+
+// touch will record an access of the node for EvictLRU and EvictLFU.
+// For EvictLRU, access is the value of a clock increased by each access. For EvictLFU, it's the count of accesses.
+func (s *ConcurrentSkipList) touch(node *Node) {
+	switch s.eviction {
+	case EvictLRU:
+		atomic.StoreUint64(&node.access, atomic.AddUint64(&s.clock, 1))
+	case EvictLFU:
+		atomic.AddUint64(&node.access, 1)
+	}
+}
+
+// accessed will record an access of the node if the eviction policy tracks accesses.
+// The caller must hold the write lock.
+func (s *skipList) accessed(node *Node) {
+	if s.touch != nil {
+		s.touch(node)
+	}
+}
+
+// checkCapacity will evict the nodes beyond the capacity and call onEvict with them.
+// It's called after the writes release the locks of shards, and each node is evicted under the lock of
+// its own shard like PopFirst, so no lock of other shards is held while evicting and the order of locking
+// shards in transactions is kept. Evictions are serialized, so concurrent writers don't evict too many nodes.
+func (s *ConcurrentSkipList) checkCapacity() {
+	if s.capacity == 0 || s.Length() <= s.capacity {
+		return
+	}
+
+	var evicted []*Node
+	s.evictMutex.Lock()
+	for s.Length() > s.capacity {
+		node, ok := s.evict()
+		if !ok {
+			break
+		}
+
+		evicted = append(evicted, node)
+	}
+	s.evictMutex.Unlock()
+
+	if s.onEvict != nil {
+		for _, node := range evicted {
+			s.onEvict(node.index, node.value)
+		}
+	}
+}
+
+// evict will remove a node chosen by the eviction policy and return it.
+// If skip list is empty, return nil and false.
+func (s *ConcurrentSkipList) evict() (*Node, bool) {
+	switch s.eviction {
+	case EvictHighest:
+		return s.PopLast()
+	case EvictLRU, EvictLFU:
+		return s.evictSampled()
+	default:
+		return s.PopFirst()
+	}
+}
+
+// evictSampled will remove the node with the least access among the nodes at random positions.
+// The positions are located by spans in O(log n). If the node is changed before removing, sample again.
+func (s *ConcurrentSkipList) evictSampled() (*Node, bool) {
+	for {
+		length := s.Length()
+		if length == 0 {
+			return nil, false
+		}
+
+		var victim *Node
+		for i := 0; i < evictionSamples; i++ {
+			node := s.sample(s.randomPosition(length))
+			if node != nil && (victim == nil || atomic.LoadUint64(&node.access) < atomic.LoadUint64(&victim.access)) {
+				victim = node
+			}
+		}
+
+		if victim != nil && s.shardFor(victim.index).(*skipList).remove(victim) {
+			return victim, true
+		}
+	}
+}
+
+// sample will return the live node at the given position of the whole skip list, or nil if position is
+// out of range. Unlike At, the node is not copied, so its access can be read and it can be removed by identity.
+func (s *ConcurrentSkipList) sample(position int32) *Node {
+	for _, sl := range s.loadTable().skipLists {
+		l := sl.getLength()
+		if position >= l {
+			position -= l
+			continue
+		}
+
+		return sl.(*skipList).sample(position)
+	}
+
+	return nil
+}
+
+// sample will return the live node at the given position, or nil if position is out of range.
+func (s *skipList) sample(position int32) *Node {
+	s.rlock()
+	defer s.mutex.RUnlock()
+
+	return s.findByPosition(position)
+}
+
+// randomPosition will return a random position in [0, length) using the random source of skip list.
+func (s *ConcurrentSkipList) randomPosition(length int32) int32 {
+	if s.random != nil {
+		return s.random.Int31n(length)
+	}
+
+	return rand.Int31n(length)
+}
+
+// remove will remove the given node if it's still in the skip list and return whether it's removed.
+func (s *skipList) remove(node *Node) bool {
+	if !s.lock() {
+		return false
+	}
+	defer s.unlock()
+
+	previousNodes, _, currentNode := s.searchWithPreviousNodes(node.index)
+	if currentNode != node {
+		return false
+	}
+
+	s.unlink(previousNodes, currentNode)
+	return true
+}
+
+// appendNode will append a copy of the node keeping its access.
+func (b *listBuilder[K, V]) appendNode(node *Element[K, V]) {
+	b.append(node.index, node.value)
+	b.lastNodes[0].access = atomic.LoadUint64(&node.access)
+}
@@ -0,0 +1,124 @@
+package ConcurrentSkipList
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestConcurrentSkipList_Capacity(t *testing.T) {
+	t.Run("test lowest and highest", func(t *testing.T) {
+		for _, eviction := range []EvictionPolicy{EvictLowest, EvictHighest} {
+			var evicted []uint64
+			skipList, _ := NewConcurrentSkipListWithOptions(Options{
+				MaxLevel: 12,
+				Shards:   4,
+				Capacity: 10,
+				Eviction: eviction,
+				OnEvict: func(index uint64, value interface{}) {
+					evicted = append(evicted, index)
+				},
+			})
+
+			indexes := newShardIndexes(4)
+			for i := uint64(0); i < 20; i++ {
+				skipList.Insert(indexes[i%4]-i, i)
+			}
+
+			if skipList.Length() != 10 || len(evicted) != 10 {
+				t.Fatalf("length = %d, evicted %d nodes, want 10", skipList.Length(), len(evicted))
+			}
+
+			first, _ := skipList.First()
+			last, _ := skipList.Last()
+			for _, index := range evicted {
+				if (eviction == EvictLowest && index > first.Index()) || (eviction == EvictHighest && index < last.Index()) {
+					t.Errorf("policy %d evicted %d, remaining [%d, %d]", eviction, index, first.Index(), last.Index())
+				}
+			}
+		}
+	})
+
+	t.Run("test LRU and LFU", func(t *testing.T) {
+		for _, eviction := range []EvictionPolicy{EvictLRU, EvictLFU} {
+			skipList, _ := NewConcurrentSkipListWithOptions(Options{
+				MaxLevel: 12,
+				Capacity: 100,
+				Eviction: eviction,
+				Source:   rand.NewSource(1),
+			})
+
+			for i := uint64(0); i < 100; i++ {
+				skipList.Insert(i, i)
+			}
+
+			// Access the first half, so the second half is evicted first.
+			for i := uint64(0); i < 50; i++ {
+				skipList.Search(i)
+			}
+
+			for i := uint64(100); i < 150; i++ {
+				skipList.Insert(i, i)
+			}
+
+			// The nodes are sampled, so a few accessed nodes may be evicted.
+			hot, cold := 0, 0
+			skipList.ForEach(func(node *Node) bool {
+				if node.Index() < 50 {
+					hot++
+				} else if node.Index() < 100 {
+					cold++
+				}
+
+				return true
+			})
+
+			if skipList.Length() != 100 || hot < 2*cold {
+				t.Errorf("policy %d keeps %d accessed nodes and %d other nodes, length is %d", eviction, hot, cold, skipList.Length())
+			}
+		}
+	})
+
+	t.Run("test parallel", func(t *testing.T) {
+		var evicted int32
+		skipList, _ := NewConcurrentSkipListWithOptions(Options{
+			MaxLevel: 12,
+			Capacity: 100,
+			Eviction: EvictLRU,
+			OnEvict: func(index uint64, value interface{}) {
+				atomic.AddInt32(&evicted, 1)
+			},
+		})
+
+		wg := &sync.WaitGroup{}
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					index := uint64(i*1000 + j)
+					skipList.Insert(index, j)
+					skipList.Search(index - 1)
+				}
+			}(i)
+		}
+
+		wg.Wait()
+		if skipList.Length() != 100 || atomic.LoadInt32(&evicted) != 8000-100 {
+			t.Errorf("length = %d, evicted %d nodes", skipList.Length(), atomic.LoadInt32(&evicted))
+		}
+	})
+
+	t.Run("test invalid options", func(t *testing.T) {
+		for _, options := range []Options{
+			{MaxLevel: 12, Capacity: -1},
+			{MaxLevel: 12, Eviction: EvictLFU + 1},
+			{MaxLevel: 12, Eviction: EvictLRU, LockFree: true},
+		} {
+			if _, err := NewConcurrentSkipListWithOptions(options); err == nil {
+				t.Errorf("NewConcurrentSkipListWithOptions(%+v) should return an error", options)
+			}
+		}
+	})
+}
@@ -0,0 +1,238 @@
+package ConcurrentSkipList
+
+import (
+	"cmp"
+	"errors"
+	"math/rand"
+	"sort"
+	"sync/atomic"
+)
+
+// SkipListOptions is the configuration of NewSkipListWithOptions.
+// The zero value of each field except MaxLevel means the default value.
+type SkipListOptions[K any] struct {
+	// MaxLevel is the level of each shard, it must between 1 to 32.
+	MaxLevel int
+
+	// Probability is the probability of promoting a node to the next level, it must between 0 to 1.
+	// The default value is PROBABILITY.
+	Probability float64
+
+	// Source is the random source used to generate the level of nodes. The default source is the
+	// shared source of math/rand. Source is not required to be thread-safe, the access of it is serialized.
+	Source rand.Source
+
+	// SplitPoints are the maximum indexes of the shards except the last one, in ascending order.
+	// The indexes greater than all split points belong to the last shard. The default is one shard.
+	SplitPoints []K
+}
+
+// SkipList is a thread-safe skip list whose index and value are typed by K and V.
+// Like ConcurrentSkipList, the indexes are partitioned by range into shards protected by their own lock,
+// and each shard maintains the spans of links, so the position functions take O(log n).
+// ConcurrentSkipList is built on the same shards with uint64 indexes and interface{} values.
+// Different from ConcurrentSkipList, the zero value is a valid value.
+type SkipList[K any, V any] struct {
+	level   int
+	compare func(a, b K) int
+	// splitPoints[i] is the maximum index of shards[i].
+	splitPoints []K
+	shards      []*list[K, V]
+}
+
+// NewSkipList will create a new skip list whose indexes are ordered by their natural order.
+// Level must between 1 to 32. If not, will return an error.
+func NewSkipList[K cmp.Ordered, V any](level int) (*SkipList[K, V], error) {
+	return NewSkipListWithOptions[K, V](cmp.Compare[K], SkipListOptions[K]{MaxLevel: level})
+}
+
+// NewSkipListFunc will create a new skip list whose indexes are ordered by compare.
+// compare(a, b) should return a negative number when a < b, a positive number when a > b and zero when a == b.
+// Level must between 1 to 32 and compare must not be nil. If not, will return an error.
+func NewSkipListFunc[K any, V any](level int, compare func(a, b K) int) (*SkipList[K, V], error) {
+	return NewSkipListWithOptions[K, V](compare, SkipListOptions[K]{MaxLevel: level})
+}
+
+// NewSkipListWithOptions will create a new skip list whose indexes are ordered by compare with given options.
+// If compare is nil or any option is invalid, will return an error.
+func NewSkipListWithOptions[K any, V any](compare func(a, b K) int, options SkipListOptions[K]) (*SkipList[K, V], error) {
+	if options.MaxLevel <= 0 || options.MaxLevel > MAX_LEVEL {
+		return nil, errors.New("invalid level, level must between 1 to 32")
+	}
+
+	if compare == nil {
+		return nil, errors.New("invalid compare function, compare must not be nil")
+	}
+
+	if options.Probability == 0 {
+		options.Probability = PROBABILITY
+	}
+
+	if options.Probability <= 0 || options.Probability >= 1 {
+		return nil, errors.New("invalid probability, probability must between 0 to 1")
+	}
+
+	for i := 1; i < len(options.SplitPoints); i++ {
+		if compare(options.SplitPoints[i-1], options.SplitPoints[i]) >= 0 {
+			return nil, errors.New("invalid split points, split points must be in ascending order")
+		}
+	}
+
+	var random *rand.Rand
+	if options.Source != nil {
+		random = rand.New(&lockedSource{source: options.Source})
+	}
+
+	s := &SkipList[K, V]{
+		level:       options.MaxLevel,
+		compare:     compare,
+		splitPoints: append([]K(nil), options.SplitPoints...),
+		shards:      make([]*list[K, V], len(options.SplitPoints)+1),
+	}
+	for i := range s.shards {
+		s.shards[i] = &list[K, V]{}
+		s.shards[i].init(options.MaxLevel, options.Probability, random, compare)
+	}
+
+	return s, nil
+}
+
+// Level will return the level of skip list.
+func (s *SkipList[K, V]) Level() int {
+	return s.level
+}
+
+// Length will return the length of skip list.
+func (s *SkipList[K, V]) Length() int32 {
+	var length int32
+	for _, sl := range s.shards {
+		length += atomic.LoadInt32(&sl.length)
+	}
+
+	return length
+}
+
+// shard will return the position of the shard which given index belongs to.
+func (s *SkipList[K, V]) shard(index K) int {
+	return sort.Search(len(s.splitPoints), func(i int) bool {
+		return s.compare(s.splitPoints[i], index) >= 0
+	})
+}
+
+// Search will search the skip list with the given index.
+// If the index exists, return a copy of the element and true, otherwise return nil and false.
+func (s *SkipList[K, V]) Search(index K) (*Element[K, V], bool) {
+	sl := s.shards[s.shard(index)]
+	sl.mutex.RLock()
+	defer sl.mutex.RUnlock()
+
+	if element := sl.find(index); element != nil {
+		return element.copy(), true
+	}
+
+	return nil, false
+}
+
+// Insert will insert a value into skip list. If skip has these this index, overwrite the value, otherwise add it.
+func (s *SkipList[K, V]) Insert(index K, value V) {
+	sl := s.shards[s.shard(index)]
+	sl.mutex.Lock()
+	defer sl.mutex.Unlock()
+
+	sl.insertLocked(index, value)
+}
+
+// Delete the element with the given index.
+func (s *SkipList[K, V]) Delete(index K) {
+	sl := s.shards[s.shard(index)]
+	sl.mutex.Lock()
+	defer sl.mutex.Unlock()
+
+	sl.deleteLocked(index)
+}
+
+// ForEach will create a snapshot first shard by shard. Then iterate each element in snapshot and do the function f().
+// If f() return false, stop iterating and return.
+func (s *SkipList[K, V]) ForEach(f func(element *Element[K, V]) bool) {
+	for _, sl := range s.shards {
+		sl.mutex.RLock()
+		elements := sl.copyFrom(sl.head.nextNodes[0], sl.length)
+		sl.mutex.RUnlock()
+
+		for _, element := range elements {
+			if !f(element) {
+				return
+			}
+		}
+	}
+}
+
+// Sub will return a slice the skip list who starts with startNumber.
+// The startNumber start with 0 as same as slice and maximum length is skip list's length.
+// The shard containing startNumber is seeked by spans in O(log n), so only the returned elements are copied.
+func (s *SkipList[K, V]) Sub(startNumber int32, length int32) []*Element[K, V] {
+	// Ignore invalid parameter.
+	if startNumber < 0 || length <= 0 {
+		return nil
+	}
+
+	var result []*Element[K, V]
+	for _, sl := range s.shards {
+		if int32(len(result)) == length {
+			break
+		}
+
+		sl.mutex.RLock()
+		if startNumber >= sl.length {
+			startNumber -= sl.length
+		} else {
+			result = append(result, sl.copyFrom(sl.findByPosition(startNumber), length-int32(len(result)))...)
+			startNumber = 0
+		}
+		sl.mutex.RUnlock()
+	}
+
+	return result
+}
+
+// Rank will return the position of the element with given index in the whole skip list.
+// The position starts with 0 as same as Sub. If the index doesn't exist, return -1 and false.
+func (s *SkipList[K, V]) Rank(index K) (int32, bool) {
+	shard := s.shard(index)
+	sl := s.shards[shard]
+	sl.mutex.RLock()
+	rank := sl.findRank(index)
+	sl.mutex.RUnlock()
+	if rank < 0 {
+		return -1, false
+	}
+
+	for i := 0; i < shard; i++ {
+		rank += atomic.LoadInt32(&s.shards[i].length)
+	}
+
+	return rank, true
+}
+
+// At will return a copy of the element at the given position of the whole skip list.
+// The position starts with 0 as same as Sub. If position is out of range, return nil and false.
+func (s *SkipList[K, V]) At(position int32) (*Element[K, V], bool) {
+	if position < 0 {
+		return nil, false
+	}
+
+	for _, sl := range s.shards {
+		sl.mutex.RLock()
+		if position >= sl.length {
+			position -= sl.length
+			sl.mutex.RUnlock()
+			continue
+		}
+
+		element := sl.findByPosition(position).copy()
+		sl.mutex.RUnlock()
+		return element, true
+	}
+
+	return nil, false
+}
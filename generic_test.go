@@ -0,0 +1,280 @@
+package ConcurrentSkipList
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewSkipList(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   int
+		compare func(a, b string) int
+	}{
+		{"test1", -1, strings.Compare},
+		{"test2", 64, strings.Compare},
+		{"test3", 8, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := NewSkipListFunc[string, int](tt.level, tt.compare); got != nil || err == nil {
+				t.Errorf("NewSkipListFunc() = %#v,%#v", got, err)
+			}
+		})
+	}
+}
+
+func TestSkipList_Insert(t *testing.T) {
+	skipList, _ := NewSkipList[string, int](8)
+	for i := 9; i >= 0; i-- {
+		skipList.Insert(strconv.Itoa(i), i)
+	}
+
+	// Zero value is a valid value.
+	skipList.Insert("", 0)
+	skipList.Insert("5", 55)
+
+	t.Run("test length", func(t *testing.T) {
+		if length := skipList.Length(); length != 11 {
+			t.Errorf("skip list's length is not correct, got %d", length)
+		}
+	})
+
+	tests := []struct {
+		name  string
+		index string
+		want  int
+	}{
+		{"test1", "", 0},
+		{"test2", "0", 0},
+		{"test3", "5", 55},
+		{"test4", "9", 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, ok := skipList.Search(tt.index); !ok || got.Value() != tt.want {
+				t.Errorf("Search() = %v, want = %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("test sequence", func(t *testing.T) {
+		var lastIndex string
+		skipList.ForEach(func(element *Element[string, int]) bool {
+			if lastIndex > element.Index() {
+				t.Errorf("incorrect sequence")
+			}
+
+			lastIndex = element.Index()
+			return true
+		})
+	})
+}
+
+func TestSkipList_Delete(t *testing.T) {
+	skipList, _ := NewSkipList[int, string](8)
+	skipList.Delete(1)
+	for i := 0; i <= 10; i++ {
+		skipList.Insert(i, strconv.Itoa(i))
+	}
+
+	skipList.Delete(5)
+	skipList.Delete(1)
+	skipList.Delete(11)
+
+	t.Run("test length", func(t *testing.T) {
+		if length := skipList.Length(); length != 9 {
+			t.Errorf("skip list's length is not correct, got %d", length)
+		}
+	})
+
+	tests := []struct {
+		name  string
+		index int
+		want  bool
+	}{
+		{"test1", 0, true},
+		{"test2", 1, false},
+		{"test3", 5, false},
+		{"test4", 10, true},
+		{"test5", 11, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := skipList.Search(tt.index); ok != tt.want {
+				t.Errorf("Search() = %v, want = %v", ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkipList_Comparator(t *testing.T) {
+	type point struct {
+		x, y int
+	}
+
+	// Order by x descending, then y ascending.
+	skipList, _ := NewSkipListFunc[point, string](8, func(a, b point) int {
+		if a.x != b.x {
+			return b.x - a.x
+		}
+
+		return a.y - b.y
+	})
+
+	var wg sync.WaitGroup
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 10; y++ {
+			wg.Add(1)
+			go func(p point) {
+				defer wg.Done()
+				skipList.Insert(p, fmt.Sprintf("%d-%d", p.x, p.y))
+			}(point{x, y})
+		}
+	}
+
+	wg.Wait()
+	t.Run("test length", func(t *testing.T) {
+		if length := skipList.Length(); length != 100 {
+			t.Errorf("skip list's length is not correct, got %d", length)
+		}
+	})
+
+	got := skipList.Sub(0, 3)
+	want := []string{"9-0", "9-1", "9-2"}
+	for i := range want {
+		t.Run(fmt.Sprintf("test%d", i+1), func(t *testing.T) {
+			if got[i].Value() != want[i] {
+				t.Errorf("Sub() = %v, want = %v", got[i].Value(), want[i])
+			}
+		})
+	}
+
+	tests := []struct {
+		name        string
+		startNumber int32
+		length      int32
+		want        int
+	}{
+		{"test4", 0, -1, 0},
+		{"test5", -1, 1, 0},
+		{"test6", 101, 1, 0},
+		{"test7", 95, 10, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := skipList.Sub(tt.startNumber, tt.length); len(got) != tt.want {
+				t.Errorf("Sub() = %v, want = %v", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSkipListWithOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options SkipListOptions[int]
+	}{
+		{"test1", SkipListOptions[int]{MaxLevel: 0}},
+		{"test2", SkipListOptions[int]{MaxLevel: 8, Probability: 1}},
+		{"test3", SkipListOptions[int]{MaxLevel: 8, SplitPoints: []int{10, 10}}},
+		{"test4", SkipListOptions[int]{MaxLevel: 8, SplitPoints: []int{20, 10}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := NewSkipListWithOptions[int, int](cmp.Compare[int], tt.options); got != nil || err == nil {
+				t.Errorf("NewSkipListWithOptions() = %#v,%#v", got, err)
+			}
+		})
+	}
+}
+
+func TestSkipList_Shards(t *testing.T) {
+	skipList, err := NewSkipListWithOptions[int, int](cmp.Compare[int], SkipListOptions[int]{
+		MaxLevel:    8,
+		Probability: 0.5,
+		Source:      rand.NewSource(1),
+		SplitPoints: []int{9, 19, 29},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 39; i >= 0; i-- {
+		skipList.Insert(i, i*10)
+	}
+
+	skipList.Delete(15)
+	t.Run("test length", func(t *testing.T) {
+		if length := skipList.Length(); length != 39 {
+			t.Errorf("skip list's length is not correct, got %d", length)
+		}
+
+		for i, length := range []int32{10, 9, 10, 10} {
+			if got := skipList.shards[i].length; got != length {
+				t.Errorf("shard %d's length is not correct, got %d", i, got)
+			}
+		}
+	})
+
+	t.Run("test rank and at", func(t *testing.T) {
+		for i := 0; i < 40; i++ {
+			rank, ok := skipList.Rank(i)
+			if i == 15 {
+				if ok {
+					t.Errorf("Rank(%d) = %d, want none", i, rank)
+				}
+
+				continue
+			}
+
+			want := int32(i)
+			if i > 15 {
+				want--
+			}
+
+			if !ok || rank != want {
+				t.Errorf("Rank(%d) = %d, want = %d", i, rank, want)
+			}
+
+			if element, ok := skipList.At(want); !ok || element.Index() != i || element.Value() != i*10 {
+				t.Errorf("At(%d) = %v, want = %d", want, element, i)
+			}
+		}
+
+		if element, ok := skipList.At(39); ok {
+			t.Errorf("At(39) = %v, want none", element)
+		}
+	})
+
+	t.Run("test sub", func(t *testing.T) {
+		got := skipList.Sub(8, 10)
+		want := []int{8, 9, 10, 11, 12, 13, 14, 16, 17, 18}
+		if len(got) != len(want) {
+			t.Fatalf("Sub() = %v, want = %v", got, want)
+		}
+
+		for i := range want {
+			if got[i].Index() != want[i] {
+				t.Errorf("Sub()[%d] = %d, want = %d", i, got[i].Index(), want[i])
+			}
+		}
+	})
+
+	t.Run("test for each", func(t *testing.T) {
+		count := 0
+		skipList.ForEach(func(element *Element[int, int]) bool {
+			count++
+			return element.Index() < 25
+		})
+
+		if count != 25 {
+			t.Errorf("ForEach() visited %d elements, want 25", count)
+		}
+	})
+}
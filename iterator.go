@@ -0,0 +1,104 @@
+package ConcurrentSkipList
+
+import (
+	"math"
+)
+
+// Iterator is a pull-based iterator of ConcurrentSkipList created by NewIterator.
+// An iterator is positioned at a node or invalid. A new iterator is invalid until it's seeked.
+//
+// The iterator doesn't copy the skip list or hold locks between calls, it keeps a copy of the current node
+// made under the read lock of its shard. Each move finds the next node from
+// the index of current node in the live skip list, so the semantics under concurrent Insert and Delete are:
+//   - Next moves to the least index greater than the current index at the moment of calling,
+//     Prev moves to the greatest index less than the current index.
+//   - The nodes inserted ahead of the iterator are visited and the nodes deleted ahead are not.
+//   - Index and Value return the node as it was when the iterator moved to it, even if it's deleted later.
+//
+// An iterator is not safe for concurrent use, but different iterators may be used concurrently.
+// If the shards are not ordered, each move visits all shards to keep the global order.
+type Iterator struct {
+	list *ConcurrentSkipList
+	node *Node
+}
+
+// NewIterator will create an invalid iterator of the skip list, call Seek, SeekToFirst or SeekToLast before reading.
+func (s *ConcurrentSkipList) NewIterator() *Iterator {
+	return &Iterator{
+		list: s,
+	}
+}
+
+// Seek will move to the node with the least index >= given index and return whether the iterator is valid.
+func (it *Iterator) Seek(index uint64) bool {
+	return it.move(it.list.Ceiling(index))
+}
+
+// SeekToFirst will move to the node with the least index and return whether the iterator is valid.
+func (it *Iterator) SeekToFirst() bool {
+	return it.move(it.list.First())
+}
+
+// SeekToLast will move to the node with the greatest index and return whether the iterator is valid.
+func (it *Iterator) SeekToLast() bool {
+	return it.move(it.list.Last())
+}
+
+// Next will move to the node with the least index > current index and return whether the iterator is valid.
+// If the iterator is invalid, Next does nothing and return false.
+func (it *Iterator) Next() bool {
+	if !it.Valid() {
+		return false
+	}
+
+	if it.node.index == math.MaxUint64 {
+		return it.move(nil, false)
+	}
+
+	return it.move(it.list.Ceiling(it.node.index + 1))
+}
+
+// Prev will move to the node with the greatest index < current index and return whether the iterator is valid.
+// If the iterator is invalid, Prev does nothing and return false.
+func (it *Iterator) Prev() bool {
+	if !it.Valid() {
+		return false
+	}
+
+	if it.node.index == 0 {
+		return it.move(nil, false)
+	}
+
+	return it.move(it.list.Floor(it.node.index - 1))
+}
+
+// Valid will return whether the iterator is positioned at a node.
+func (it *Iterator) Valid() bool {
+	return it.node != nil
+}
+
+// Index will return the index of current node. The iterator must be valid.
+func (it *Iterator) Index() uint64 {
+	return it.node.index
+}
+
+// Value will return the value of current node. The iterator must be valid.
+func (it *Iterator) Value() interface{} {
+	return it.node.value
+}
+
+// Close will release the iterator, then the iterator is invalid and must not be used again.
+func (it *Iterator) Close() {
+	it.node = nil
+	it.list = nil
+}
+
+// move will position the iterator at given node, an invalid position if ok is false.
+func (it *Iterator) move(node *Node, ok bool) bool {
+	if !ok {
+		node = nil
+	}
+
+	it.node = node
+	return ok
+}
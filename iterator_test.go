@@ -0,0 +1,182 @@
+package ConcurrentSkipList
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestIterator(t *testing.T) {
+	newList := map[string]func(int) (*ConcurrentSkipList, error){
+		"locked":    NewConcurrentSkipList,
+		"lock-free": NewLockFreeConcurrentSkipList,
+	}
+	for name, f := range newList {
+		t.Run(name, func(t *testing.T) {
+			skipList, _ := f(12)
+			indexes := []uint64{0, 10, 20, shardIndexes[0], shardIndexes[0] + 1, shardIndexes[5], math.MaxUint64}
+			for _, v := range indexes {
+				skipList.Insert(v, v)
+			}
+
+			it := skipList.NewIterator()
+			defer it.Close()
+			if it.Valid() || it.Next() || it.Prev() {
+				t.Fatalf("new iterator should be invalid")
+			}
+
+			t.Run("test next", func(t *testing.T) {
+				i := 0
+				for ok := it.SeekToFirst(); ok; ok = it.Next() {
+					if it.Index() != indexes[i] || it.Value() != indexes[i] {
+						t.Errorf("got %v, want %v", it.Index(), indexes[i])
+					}
+
+					i++
+				}
+
+				if i != len(indexes) || it.Valid() {
+					t.Errorf("visited %d nodes, want %d", i, len(indexes))
+				}
+			})
+
+			t.Run("test prev", func(t *testing.T) {
+				i := len(indexes) - 1
+				for ok := it.SeekToLast(); ok; ok = it.Prev() {
+					if it.Index() != indexes[i] {
+						t.Errorf("got %v, want %v", it.Index(), indexes[i])
+					}
+
+					i--
+				}
+
+				if i != -1 {
+					t.Errorf("visited %d nodes, want %d", len(indexes)-1-i, len(indexes))
+				}
+			})
+
+			t.Run("test seek", func(t *testing.T) {
+				if !it.Seek(11) || it.Index() != 20 {
+					t.Errorf("Seek(11) = %v, want 20", it.Index())
+				}
+
+				if !it.Seek(21) || it.Index() != shardIndexes[0] {
+					t.Errorf("Seek(21) = %v, want %v", it.Index(), shardIndexes[0])
+				}
+
+				if !it.Prev() || it.Index() != 20 {
+					t.Errorf("Prev() = %v, want 20", it.Index())
+				}
+			})
+
+			t.Run("test modify while iterating", func(t *testing.T) {
+				it.Seek(10)
+				skipList.Insert(15, 15)
+				skipList.Delete(20)
+				if !it.Next() || it.Index() != 15 {
+					t.Errorf("Next() = %v, want 15", it.Index())
+				}
+
+				skipList.Delete(15)
+				if it.Index() != 15 || it.Value() != 15 {
+					t.Errorf("deleted node should be kept, got %v", it.Index())
+				}
+
+				if !it.Next() || it.Index() != shardIndexes[0] {
+					t.Errorf("Next() = %v, want %v", it.Index(), shardIndexes[0])
+				}
+			})
+		})
+	}
+
+	t.Run("test concurrent", func(t *testing.T) {
+		skipList, _ := NewConcurrentSkipList(12)
+		count := 10000
+		for i := 0; i < count; i += 2 {
+			skipList.Insert(uint64(i), i)
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 1; i < count; i += 2 {
+				skipList.Insert(uint64(i), i)
+				skipList.Delete(uint64(i))
+			}
+		}()
+
+		// The even indexes are never deleted, so they must be visited in order.
+		it := skipList.NewIterator()
+		var last uint64
+		visited := 0
+		for ok := it.SeekToFirst(); ok; ok = it.Next() {
+			if visited > 0 && it.Index() <= last {
+				t.Fatalf("Next() = %v after %v", it.Index(), last)
+			}
+
+			if it.Index()%2 == 0 {
+				visited++
+			}
+
+			last = it.Index()
+		}
+
+		wg.Wait()
+		if visited != count/2 {
+			t.Errorf("visited %d even indexes, want %d", visited, count/2)
+		}
+	})
+}
+
+func TestIterator_Overwrite(t *testing.T) {
+	newList := map[string]func(int) (*ConcurrentSkipList, error){
+		"locked":    NewConcurrentSkipList,
+		"lock-free": NewLockFreeConcurrentSkipList,
+	}
+	for name, f := range newList {
+		t.Run(name, func(t *testing.T) {
+			skipList, _ := f(12)
+			skipList.Insert(5, 0)
+
+			stop, done := make(chan struct{}), make(chan struct{})
+			go func() {
+				defer close(done)
+				for i := 1; ; i++ {
+					select {
+					case <-stop:
+						return
+					default:
+						skipList.Insert(5, i)
+					}
+				}
+			}()
+
+			it := skipList.NewIterator()
+			defer it.Close()
+			seeks := map[string]func() bool{
+				"Seek":        func() bool { return it.Seek(5) },
+				"SeekToFirst": it.SeekToFirst,
+				"SeekToLast":  it.SeekToLast,
+			}
+			for seekName, seek := range seeks {
+				for i := 0; i < 3000; i++ {
+					if !seek() {
+						t.Fatalf("%s() should find index 5", seekName)
+					}
+
+					// The value is the one when the iterator moved, it doesn't change with the writes.
+					value := it.Value()
+					for j := 0; j < 10; j++ {
+						if it.Value() != value {
+							t.Fatalf("Value() after %s() changes from %v to %v", seekName, value, it.Value())
+						}
+					}
+				}
+			}
+
+			close(stop)
+			<-done
+		})
+	}
+}
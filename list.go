@@ -0,0 +1,404 @@
+package ConcurrentSkipList
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+// list is a skip list whose indexes are ordered by compare. It's the shard of both SkipList and ConcurrentSkipList,
+// the shards of ConcurrentSkipList are lists of uint64 indexes and interface{} values, see skipList.
+// Except init, the methods don't lock and the caller must hold the lock of mutex.
+type list[K any, V any] struct {
+	level       int
+	length      int32
+	head        *Element[K, V]
+	tail        *Element[K, V]
+	mutex       sync.RWMutex
+	probability float64
+	// random is used to generate the level of nodes, nil means the shared source of math/rand.
+	random *rand.Rand
+	// compare(a, b) returns a negative number when a < b, a positive number when a > b and zero when a == b.
+	compare func(a, b K) int
+	// hooks is notified of each write, nil means no one.
+	hooks listHooks[K, V]
+}
+
+// listHooks is notified of the writes of list while the write lock is held.
+type listHooks[K any, V any] interface {
+	// changed will be called before each write of the index with its value before and after the write,
+	// the zero value means the index doesn't exist.
+	changed(index K, old, value V)
+
+	// accessed will be called after a node is inserted or updated.
+	accessed(node *Element[K, V])
+}
+
+// init will initialize the zero value of list with given level.
+// The level of nodes is promoted with given probability using given random.
+func (s *list[K, V]) init(level int, probability float64, random *rand.Rand, compare func(a, b K) int) {
+	var index K
+	var value V
+	s.level = level
+	s.head = newElement(index, value, level)
+	s.probability = probability
+	s.random = random
+	s.compare = compare
+}
+
+// searchWithPreviousNode will search given index in skip list.
+// The first return value represents the previous nodes need to update when call Insert function.
+// The second return value represents the rank of each previous node, head's rank is 0.
+// The third return value represents the value with given index or the closet value whose index is larger than given index.
+func (s *list[K, V]) searchWithPreviousNodes(index K) ([]*Element[K, V], []int32, *Element[K, V]) {
+	// Store all previous value whose index is less than index and whose next value's index is larger than index.
+	previousNodes := make([]*Element[K, V], s.level)
+	ranks := make([]int32, s.level)
+	var rank int32
+
+	currentNode := s.head
+
+	// Iterate from top level to bottom level.
+	for l := s.level - 1; l >= 0; l-- {
+		// Iterate value util value's index is >= given index.
+		// The max iterate count is skip list's length. So the worst O(n) is N.
+		for currentNode.nextNodes[l] != s.tail && s.compare(currentNode.nextNodes[l].index, index) < 0 {
+			rank += currentNode.spans[l]
+			currentNode = currentNode.nextNodes[l]
+		}
+
+		// When next value's index is >= given index, add current value whose index < given index.
+		previousNodes[l] = currentNode
+		ranks[l] = rank
+	}
+
+	// Avoid point to tail which will occur panic in Insert and Delete function.
+	// When the next value is tail.
+	// The index is larger than the maximum index in the skip list or skip list's length is 0. Don't point to tail.
+	// When the next value isn't tail.
+	// Next value's index must >= given index. Point to it.
+	if currentNode.nextNodes[0] != s.tail {
+		currentNode = currentNode.nextNodes[0]
+	}
+
+	return previousNodes, ranks, currentNode
+}
+
+// find will return the node whose index is given index.
+// If can not find the given index, return nil.
+// This function is faster than searchWithPreviousNodes and it used to only searching index.
+func (s *list[K, V]) find(index K) *Element[K, V] {
+	currentNode := s.findGreaterOrEqual(index)
+	if currentNode != s.tail && s.compare(currentNode.index, index) == 0 {
+		return currentNode
+	}
+
+	return nil
+}
+
+// searchFrom will search given index from the fingers and update them to the previous nodes of given index.
+// The fingers are the previous nodes and their ranks of a less index or head, so searching ascending indexes
+// one by one only visits the nodes between them. Return the first node whose index is >= given index or tail.
+func (s *list[K, V]) searchFrom(index K, previousNodes []*Element[K, V], ranks []int32) *Element[K, V] {
+	currentNode := s.head
+	var rank int32
+	for l := s.level - 1; l >= 0; l-- {
+		// Jump to the finger if it's ahead.
+		if ranks[l] > rank {
+			currentNode, rank = previousNodes[l], ranks[l]
+		}
+
+		for currentNode.nextNodes[l] != s.tail && s.compare(currentNode.nextNodes[l].index, index) < 0 {
+			rank += currentNode.spans[l]
+			currentNode = currentNode.nextNodes[l]
+		}
+
+		previousNodes[l] = currentNode
+		ranks[l] = rank
+	}
+
+	return currentNode.nextNodes[0]
+}
+
+// newFingers will create the fingers pointing to head for searchFrom.
+func (s *list[K, V]) newFingers() ([]*Element[K, V], []int32) {
+	previousNodes := make([]*Element[K, V], s.level)
+	for i := range previousNodes {
+		previousNodes[i] = s.head
+	}
+
+	return previousNodes, make([]int32, s.level)
+}
+
+// findGreaterOrEqual will return the first node whose index is >= given index.
+// If all indexes are less than given index, return tail.
+func (s *list[K, V]) findGreaterOrEqual(index K) *Element[K, V] {
+	return s.findLess(index).nextNodes[0]
+}
+
+// findLess will return the last node whose index is < given index.
+// If all indexes are >= given index, return head.
+func (s *list[K, V]) findLess(index K) *Element[K, V] {
+	currentNode := s.head
+	for l := s.level - 1; l >= 0; l-- {
+		for currentNode.nextNodes[l] != s.tail && s.compare(currentNode.nextNodes[l].index, index) < 0 {
+			currentNode = currentNode.nextNodes[l]
+		}
+	}
+
+	return currentNode
+}
+
+// findLessOrEqual will return the last node whose index is <= given index.
+// If all indexes are > given index, return head.
+func (s *list[K, V]) findLessOrEqual(index K) *Element[K, V] {
+	currentNode := s.head
+	for l := s.level - 1; l >= 0; l-- {
+		for currentNode.nextNodes[l] != s.tail && s.compare(currentNode.nextNodes[l].index, index) <= 0 {
+			currentNode = currentNode.nextNodes[l]
+		}
+	}
+
+	return currentNode
+}
+
+// findLast will return the last node of skip list.
+// If skip list is empty, return head.
+func (s *list[K, V]) findLast() *Element[K, V] {
+	currentNode := s.head
+	for l := s.level - 1; l >= 0; l-- {
+		for currentNode.nextNodes[l] != s.tail {
+			currentNode = currentNode.nextNodes[l]
+		}
+	}
+
+	return currentNode
+}
+
+// findByPosition will return the node at the given position, the first node's position is 0.
+// If position is out of range, return tail.
+func (s *list[K, V]) findByPosition(position int32) *Element[K, V] {
+	if position < 0 || position >= s.length {
+		return s.tail
+	}
+
+	// The rank of head is 0 and the rank of the node at position is position + 1.
+	var rank int32
+	currentNode := s.head
+	for l := s.level - 1; l >= 0; l-- {
+		for currentNode.nextNodes[l] != s.tail && rank+currentNode.spans[l] <= position+1 {
+			rank += currentNode.spans[l]
+			currentNode = currentNode.nextNodes[l]
+		}
+
+		if rank == position+1 {
+			return currentNode
+		}
+	}
+
+	return s.tail
+}
+
+// findRank will return the position of the node with given index, the first node's position is 0.
+// If can not find the given index, return -1.
+func (s *list[K, V]) findRank(index K) int32 {
+	var rank int32
+	currentNode := s.head
+	for l := s.level - 1; l >= 0; l-- {
+		for currentNode.nextNodes[l] != s.tail && s.compare(currentNode.nextNodes[l].index, index) <= 0 {
+			rank += currentNode.spans[l]
+			currentNode = currentNode.nextNodes[l]
+		}
+
+		if currentNode != s.head && s.compare(currentNode.index, index) == 0 {
+			return rank - 1
+		}
+	}
+
+	return -1
+}
+
+// copyFrom will return copies of at most length nodes from the given node in ascending order.
+func (s *list[K, V]) copyFrom(currentNode *Element[K, V], length int32) []*Element[K, V] {
+	var result []*Element[K, V]
+	for ; currentNode != s.tail && int32(len(result)) < length; currentNode = currentNode.nextNodes[0] {
+		result = append(result, currentNode.copy())
+	}
+
+	return result
+}
+
+// insertLocked will insert a value into skip list and update the length.
+// If skip has these this index, overwrite the value and return the previous value and true, otherwise add it.
+func (s *list[K, V]) insertLocked(index K, value V) (V, bool) {
+	previousNodes, ranks, currentNode := s.searchWithPreviousNodes(index)
+
+	if currentNode != s.head && s.compare(currentNode.index, index) == 0 {
+		previous := currentNode.value
+		s.set(currentNode, value)
+		return previous, true
+	}
+
+	s.link(previousNodes, ranks, index, value)
+	var zero V
+	return zero, false
+}
+
+// set will overwrite the value of the node.
+func (s *list[K, V]) set(node *Element[K, V], value V) {
+	if s.hooks != nil {
+		s.hooks.changed(node.index, node.value, value)
+	}
+
+	node.value = value
+	if s.hooks != nil {
+		s.hooks.accessed(node)
+	}
+}
+
+// link will link a new node after the previous nodes, update the length and spans and return the new node.
+// previousNodes and ranks must be the result of searchWithPreviousNodes with given index.
+func (s *list[K, V]) link(previousNodes []*Element[K, V], ranks []int32, index K, value V) *Element[K, V] {
+	if s.hooks != nil {
+		var zero V
+		s.hooks.changed(index, zero, value)
+	}
+
+	// Make a new value.
+	newNode := newElement(index, value, s.randomLevel())
+	newNode.previousNode = previousNodes[0]
+	if s.hooks != nil {
+		s.hooks.accessed(newNode)
+	}
+
+	// Adjust pointer. Similar to update linked list.
+	for i := len(newNode.nextNodes) - 1; i >= 0; i-- {
+		// Firstly, new value point to next value.
+		newNode.nextNodes[i] = previousNodes[i].nextNodes[i]
+
+		// Secondly, previous nodes point to new value.
+		previousNodes[i].nextNodes[i] = newNode
+
+		// Split the span of previous node. ranks[0] - ranks[i] is the distance between previous node and new value's previous node.
+		newNode.spans[i] = previousNodes[i].spans[i] - (ranks[0] - ranks[i])
+		previousNodes[i].spans[i] = ranks[0] - ranks[i] + 1
+
+		// Finally, in order to release the slice, point to nil.
+		previousNodes[i] = nil
+	}
+
+	// The links above new value's level cross over new value.
+	for i := len(newNode.nextNodes); i < len(previousNodes); i++ {
+		previousNodes[i].spans[i]++
+	}
+
+	// Update the backward link of the next value.
+	if newNode.nextNodes[0] != s.tail {
+		newNode.nextNodes[0].previousNode = newNode
+	}
+
+	atomic.AddInt32(&s.length, 1)
+
+	for i := len(newNode.nextNodes); i < len(previousNodes); i++ {
+		previousNodes[i] = nil
+	}
+
+	return newNode
+}
+
+// deleteLocked will find the index is existed or not firstly.
+// If existed, delete it, update length and return the deleted value and true, otherwise do nothing.
+func (s *list[K, V]) deleteLocked(index K) (V, bool) {
+	previousNodes, _, currentNode := s.searchWithPreviousNodes(index)
+
+	// If skip list length is 0 or could not find value with the given index.
+	if currentNode != s.head && s.compare(currentNode.index, index) == 0 {
+		s.unlink(previousNodes, currentNode)
+		return currentNode.value, true
+	}
+
+	for i := len(currentNode.nextNodes); i < len(previousNodes); i++ {
+		previousNodes[i] = nil
+	}
+
+	var zero V
+	return zero, false
+}
+
+// unlink will remove the given node from skip list and update the length and spans.
+// previousNodes must be the result of searchWithPreviousNodes with the node's index.
+func (s *list[K, V]) unlink(previousNodes []*Element[K, V], currentNode *Element[K, V]) {
+	if s.hooks != nil {
+		var zero V
+		s.hooks.changed(currentNode.index, currentNode.value, zero)
+	}
+
+	// Update the backward link of the next value.
+	if currentNode.nextNodes[0] != s.tail {
+		currentNode.nextNodes[0].previousNode = currentNode.previousNode
+	}
+	currentNode.previousNode = nil
+
+	// Adjust pointer. Similar to update linked list.
+	for i := 0; i < len(previousNodes); i++ {
+		if i < len(currentNode.nextNodes) {
+			previousNodes[i].spans[i] += currentNode.spans[i] - 1
+			previousNodes[i].nextNodes[i] = currentNode.nextNodes[i]
+			currentNode.nextNodes[i] = nil
+		} else {
+			// The links above current value's level cross over current value.
+			previousNodes[i].spans[i]--
+		}
+
+		previousNodes[i] = nil
+	}
+
+	atomic.AddInt32(&s.length, -1)
+}
+
+// listBuilder appends nodes to an empty skip list without searching.
+type listBuilder[K any, V any] struct {
+	s *list[K, V]
+	// lastNodes[i] is the last node of level i and ranks[i] is its rank, head's rank is 0.
+	lastNodes []*Element[K, V]
+	ranks     []int32
+	length    int32
+}
+
+// newListBuilder will return a builder appending nodes to the empty skip list.
+func (s *list[K, V]) newListBuilder() *listBuilder[K, V] {
+	lastNodes, ranks := s.newFingers()
+	return &listBuilder[K, V]{
+		s:         s,
+		lastNodes: lastNodes,
+		ranks:     ranks,
+	}
+}
+
+// append will link a new node after the last node of each level it reaches.
+func (b *listBuilder[K, V]) append(index K, value V) {
+	b.length++
+	newNode := newElement(index, value, b.s.randomLevel())
+	newNode.previousNode = b.lastNodes[0]
+	for i := range newNode.nextNodes {
+		b.lastNodes[i].nextNodes[i] = newNode
+		b.lastNodes[i].spans[i] = b.length - b.ranks[i]
+		b.lastNodes[i], b.ranks[i] = newNode, b.length
+	}
+}
+
+// finish will link the last node of each level to tail, the span to tail counts the nodes after it.
+func (b *listBuilder[K, V]) finish() {
+	for i, lastNode := range b.lastNodes {
+		lastNode.nextNodes[i] = b.s.tail
+		lastNode.spans[i] = b.length - b.ranks[i]
+	}
+
+	atomic.StoreInt32(&b.s.length, b.length)
+}
+
+// randomLevel will generate and random level that level > 0 and level < skip list's level
+// This comes from redis's implementation.
+func (s *list[K, V]) randomLevel() int {
+	return randomLevel(s.level, s.probability, s.random)
+}
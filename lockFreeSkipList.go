@@ -0,0 +1,682 @@
+package ConcurrentSkipList
+
+import (
+	"math"
+	"math/rand"
+	"sync/atomic"
+)
+
+// lockFreeLink is an immutable link to the next node with a mark.
+// The mark means the node owning this link is logically deleted at this level.
+// A link is replaced as a whole by CAS, so the pointer and the mark are updated atomically,
+// which is the same as AtomicMarkableReference in Java.
+type lockFreeLink struct {
+	node   *lockFreeNode
+	marked bool
+}
+
+// lockFreeNode is a node of lockFreeSkipList.
+type lockFreeNode struct {
+	index     uint64
+	value     atomic.Pointer[interface{}]
+	nextNodes []atomic.Pointer[lockFreeLink]
+}
+
+// newLockFreeNode will create a node whose links point to tail.
+func newLockFreeNode(index uint64, value interface{}, level int) *lockFreeNode {
+	node := &lockFreeNode{
+		index:     index,
+		nextNodes: make([]atomic.Pointer[lockFreeLink], level, level),
+	}
+
+	node.value.Store(&value)
+	for i := range node.nextNodes {
+		node.nextNodes[i].Store(&lockFreeLink{})
+	}
+
+	return node
+}
+
+// lockFreeTombstone replaces the value of a node when the node is deleted.
+// It keeps the value before deleting for the readers which found the node before.
+type lockFreeTombstone struct {
+	value interface{}
+}
+
+// isDeleted will return whether the node is logically deleted.
+// The node is deleted once its value is replaced by a tombstone.
+func (n *lockFreeNode) isDeleted() bool {
+	_, ok := (*n.value.Load()).(lockFreeTombstone)
+	return ok
+}
+
+// toNode will copy the node to a Node.
+// If the node is deleted, the value is the one before deleting.
+func (n *lockFreeNode) toNode() *Node {
+	value := *n.value.Load()
+	if tombstone, ok := value.(lockFreeTombstone); ok {
+		value = tombstone.value
+	}
+
+	return &Node{
+		index:     n.index,
+		value:     value,
+		nextNodes: nil,
+	}
+}
+
+// lockFreeSkipList is a lock-free skip list which comes from Fraser's and Harris's lock-free linked list.
+// See more detail in Keir Fraser's paper <Practical lock-freedom> and the LockFreeSkipList of
+// <The Art of Multiprocessor Programming>.
+// A node is deleted by replacing its value with a tombstone by CAS, which is the linearization point,
+// so the value is updated and deleted atomically. Then its links are marked from top level to level 0
+// and no node can be linked after it. Marked nodes are unlinked physically by the following traversals of find.
+// The tail is nil.
+type lockFreeSkipList struct {
+	level       int
+	length      int32
+	head        *lockFreeNode
+	probability float64
+	// random is used to generate the level of nodes, nil means the shared source of math/rand.
+	// It must be thread-safe.
+	random *rand.Rand
+}
+
+// newLockFreeSkipList will create a lock-free skip list with given level.
+// The level of nodes is promoted with given probability using given random.
+func newLockFreeSkipList(level int, probability float64, random *rand.Rand) *lockFreeSkipList {
+	return &lockFreeSkipList{
+		level:       level,
+		length:      0,
+		head:        newLockFreeNode(0, nil, level),
+		probability: probability,
+		random:      random,
+	}
+}
+
+// find will search given index and fill the previous nodes and next nodes of each level.
+// The marked nodes on the way are unlinked. If a CAS fails because of concurrent modification, retry from head.
+// Return whether the node of level 0 has the given index.
+func (s *lockFreeSkipList) find(index uint64, previousNodes, nextNodes []*lockFreeNode) bool {
+retry:
+	for {
+		previousNode := s.head
+		var currentNode *lockFreeNode
+		for l := s.level - 1; l >= 0; l-- {
+			currentNode = previousNode.nextNodes[l].Load().node
+			for currentNode != nil {
+				link := currentNode.nextNodes[l].Load()
+				for link.marked {
+					// Current node is deleted, unlink it from previous node.
+					previousLink := previousNode.nextNodes[l].Load()
+					if previousLink.node != currentNode || previousLink.marked {
+						continue retry
+					}
+
+					if !previousNode.nextNodes[l].CompareAndSwap(previousLink, &lockFreeLink{node: link.node}) {
+						continue retry
+					}
+
+					currentNode = link.node
+					if currentNode == nil {
+						break
+					}
+
+					link = currentNode.nextNodes[l].Load()
+				}
+
+				if currentNode == nil || currentNode.index >= index {
+					break
+				}
+
+				previousNode = currentNode
+				currentNode = link.node
+			}
+
+			previousNodes[l] = previousNode
+			nextNodes[l] = currentNode
+		}
+
+		return currentNode != nil && currentNode.index == index
+	}
+}
+
+// findGreaterOrEqual will return the first undeleted node whose index is >= given index without unlinking.
+// If can not find, return nil.
+func (s *lockFreeSkipList) findGreaterOrEqual(index uint64) *lockFreeNode {
+	previousNode := s.head
+	var currentNode *lockFreeNode
+	for l := s.level - 1; l >= 0; l-- {
+		currentNode = previousNode.nextNodes[l].Load().node
+		for currentNode != nil {
+			link := currentNode.nextNodes[l].Load()
+			if link.marked {
+				currentNode = link.node
+				continue
+			}
+
+			if currentNode.index >= index {
+				break
+			}
+
+			previousNode = currentNode
+			currentNode = link.node
+		}
+	}
+
+	// The node may be deleted before marking its links.
+	if currentNode != nil && currentNode.isDeleted() {
+		currentNode = s.next(currentNode)
+	}
+
+	return currentNode
+}
+
+// findLessOrEqual will return the last undeleted node whose index is <= given index without unlinking.
+// If can not find, return head.
+func (s *lockFreeSkipList) findLessOrEqual(index uint64) *lockFreeNode {
+	for {
+		previousNode := s.head
+		for l := s.level - 1; l >= 0; l-- {
+			currentNode := previousNode.nextNodes[l].Load().node
+			for currentNode != nil {
+				link := currentNode.nextNodes[l].Load()
+				if link.marked {
+					currentNode = link.node
+					continue
+				}
+
+				if currentNode.index > index {
+					break
+				}
+
+				previousNode = currentNode
+				currentNode = link.node
+			}
+		}
+
+		// The node may be deleted before marking its links. There is no backward link,
+		// so search again for the last node before it.
+		if previousNode == s.head || !previousNode.isDeleted() {
+			return previousNode
+		}
+
+		if previousNode.index == 0 {
+			return s.head
+		}
+
+		index = previousNode.index - 1
+	}
+}
+
+// next will return the first undeleted node after the given node at level 0.
+func (s *lockFreeSkipList) next(node *lockFreeNode) *lockFreeNode {
+	currentNode := node.nextNodes[0].Load().node
+	for currentNode != nil && currentNode.isDeleted() {
+		currentNode = currentNode.nextNodes[0].Load().node
+	}
+
+	return currentNode
+}
+
+// getLength will return the length of skip list.
+func (s *lockFreeSkipList) getLength() int32 {
+	return atomic.LoadInt32(&s.length)
+}
+
+// search will return the node whose index is given index.
+// If can not find the given index, return nil.
+func (s *lockFreeSkipList) search(index uint64) *Node {
+	if currentNode := s.findGreaterOrEqual(index); currentNode != nil && currentNode.index == index {
+		return currentNode.toNode()
+	}
+
+	return nil
+}
+
+// insert will insert a value into skip list and update the length.
+// If skip has these this index, overwrite the value and return the previous value and true, otherwise add it.
+func (s *lockFreeSkipList) insert(index uint64, value interface{}) (interface{}, bool) {
+	previousNodes := make([]*lockFreeNode, s.level)
+	nextNodes := make([]*lockFreeNode, s.level)
+	for {
+		if s.find(index, previousNodes, nextNodes) {
+			// Overwrite the value. If the node is deleted meanwhile, help deleting and retry.
+			currentNode := nextNodes[0]
+			old := currentNode.value.Load()
+			if _, ok := (*old).(lockFreeTombstone); ok {
+				s.deleteNode(currentNode, previousNodes, nextNodes)
+				continue
+			}
+
+			if currentNode.value.CompareAndSwap(old, &value) {
+				return *old, true
+			}
+
+			continue
+		}
+
+		if s.linkNode(index, value, previousNodes, nextNodes) {
+			return nil, false
+		}
+	}
+}
+
+// linkNode will link a new node between the previous nodes and next nodes found by find.
+// If the previous node of level 0 is changed meanwhile, return false and the caller should find again.
+func (s *lockFreeSkipList) linkNode(index uint64, value interface{}, previousNodes, nextNodes []*lockFreeNode) bool {
+	// Link level 0 first, it's the linearization point.
+	newNode := newLockFreeNode(index, value, s.randomLevel())
+	for i := range newNode.nextNodes {
+		newNode.nextNodes[i].Store(&lockFreeLink{node: nextNodes[i]})
+	}
+
+	previousLink := previousNodes[0].nextNodes[0].Load()
+	if previousLink.node != nextNodes[0] || previousLink.marked {
+		return false
+	}
+
+	if !previousNodes[0].nextNodes[0].CompareAndSwap(previousLink, &lockFreeLink{node: newNode}) {
+		return false
+	}
+
+	atomic.AddInt32(&s.length, 1)
+
+	// Link upper levels. If the new node is deleted meanwhile, stop linking.
+	for i := 1; i < len(newNode.nextNodes); i++ {
+		for {
+			link := newNode.nextNodes[i].Load()
+			if link.marked {
+				return true
+			}
+
+			if link.node != nextNodes[i] && !newNode.nextNodes[i].CompareAndSwap(link, &lockFreeLink{node: nextNodes[i]}) {
+				continue
+			}
+
+			previousLink := previousNodes[i].nextNodes[i].Load()
+			if previousLink.node == nextNodes[i] && !previousLink.marked &&
+				previousNodes[i].nextNodes[i].CompareAndSwap(previousLink, &lockFreeLink{node: newNode}) {
+				break
+			}
+
+			s.find(index, previousNodes, nextNodes)
+			if nextNodes[0] != newNode {
+				// The new node is deleted.
+				return true
+			}
+		}
+	}
+
+	return true
+}
+
+// delete will find the index is existed or not firstly.
+// If existed, delete it, update length and return the deleted value and true, otherwise do nothing.
+// If the node is deleted by others meanwhile, retry.
+func (s *lockFreeSkipList) delete(index uint64) (interface{}, bool) {
+	previousNodes := make([]*lockFreeNode, s.level)
+	nextNodes := make([]*lockFreeNode, s.level)
+	for s.find(index, previousNodes, nextNodes) {
+		if value, ok := s.deleteNode(nextNodes[0], previousNodes, nextNodes); ok {
+			return value, true
+		}
+	}
+
+	return nil, false
+}
+
+// deleteNode will delete given node and return its value before deleting.
+// Return whether this call deleted the node. If the node is deleted by others, help marking it and return false.
+func (s *lockFreeSkipList) deleteNode(node *lockFreeNode, previousNodes, nextNodes []*lockFreeNode) (interface{}, bool) {
+	for {
+		value := node.value.Load()
+		if tombstone, ok := (*value).(lockFreeTombstone); ok {
+			s.markNode(node, previousNodes, nextNodes)
+			return tombstone.value, false
+		}
+
+		if s.replaceWithTombstone(node, value, previousNodes, nextNodes) {
+			return *value, true
+		}
+	}
+}
+
+// replaceWithTombstone will delete given node if its value is still the given value.
+// Return whether the node is deleted by this call.
+func (s *lockFreeSkipList) replaceWithTombstone(node *lockFreeNode, value *interface{}, previousNodes, nextNodes []*lockFreeNode) bool {
+	var tombstone interface{} = lockFreeTombstone{value: *value}
+	if !node.value.CompareAndSwap(value, &tombstone) {
+		return false
+	}
+
+	atomic.AddInt32(&s.length, -1)
+	s.markNode(node, previousNodes, nextNodes)
+	return true
+}
+
+// markNode will mark the links of a deleted node from top level to level 0 and unlink it.
+func (s *lockFreeSkipList) markNode(node *lockFreeNode, previousNodes, nextNodes []*lockFreeNode) {
+	for l := len(node.nextNodes) - 1; l >= 0; l-- {
+		link := node.nextNodes[l].Load()
+		for !link.marked {
+			node.nextNodes[l].CompareAndSwap(link, &lockFreeLink{node: link.node, marked: true})
+			link = node.nextNodes[l].Load()
+		}
+	}
+
+	// Unlink physically.
+	s.find(node.index, previousNodes, nextNodes)
+}
+
+// lockFreeSkipListBuilder appends nodes to an empty lock-free skip list without CAS.
+type lockFreeSkipListBuilder struct {
+	s         *lockFreeSkipList
+	lastNodes []*lockFreeNode
+	length    int32
+}
+
+// newBuilder will return a builder appending nodes to the empty skip list.
+func (s *lockFreeSkipList) newBuilder() builder {
+	lastNodes := make([]*lockFreeNode, s.level)
+	for i := range lastNodes {
+		lastNodes[i] = s.head
+	}
+
+	return &lockFreeSkipListBuilder{
+		s:         s,
+		lastNodes: lastNodes,
+	}
+}
+
+// append will link a new node after the last node of each level it reaches.
+func (b *lockFreeSkipListBuilder) append(index uint64, value interface{}) {
+	b.length++
+	newNode := newLockFreeNode(index, value, b.s.randomLevel())
+	for i := range newNode.nextNodes {
+		b.lastNodes[i].nextNodes[i].Store(&lockFreeLink{node: newNode})
+		b.lastNodes[i] = newNode
+	}
+}
+
+// finish will update the length, the links of the last nodes point to tail already.
+func (b *lockFreeSkipListBuilder) finish() {
+	atomic.StoreInt32(&b.s.length, b.length)
+}
+
+// insertBatch will insert the entries one by one, a lock-free skip list is never retired.
+func (s *lockFreeSkipList) insertBatch(entries []Entry) bool {
+	for _, entry := range entries {
+		s.insert(entry.Index, entry.Value)
+	}
+
+	return true
+}
+
+// deleteBatch will delete the indexes one by one, a lock-free skip list is never retired.
+func (s *lockFreeSkipList) deleteBatch(indexes []uint64) bool {
+	for _, index := range indexes {
+		s.delete(index)
+	}
+
+	return true
+}
+
+// compute will call f() with the value of given index and store or delete by the result atomically.
+// The value is replaced by CAS, if it's changed meanwhile, f() is called again with the new value.
+func (s *lockFreeSkipList) compute(index uint64, f func(old interface{}, loaded bool) (interface{}, bool)) (interface{}, bool) {
+	previousNodes := make([]*lockFreeNode, s.level)
+	nextNodes := make([]*lockFreeNode, s.level)
+	for {
+		if !s.find(index, previousNodes, nextNodes) {
+			value, keep := f(nil, false)
+			if !keep || value == nil {
+				return nil, false
+			}
+
+			if s.linkNode(index, value, previousNodes, nextNodes) {
+				return value, true
+			}
+
+			continue
+		}
+
+		currentNode := nextNodes[0]
+		old := currentNode.value.Load()
+		if _, ok := (*old).(lockFreeTombstone); ok {
+			s.deleteNode(currentNode, previousNodes, nextNodes)
+			continue
+		}
+
+		value, keep := f(*old, true)
+		if !keep || value == nil {
+			if s.replaceWithTombstone(currentNode, old, previousNodes, nextNodes) {
+				return nil, false
+			}
+
+			continue
+		}
+
+		if currentNode.value.CompareAndSwap(old, &value) {
+			return value, true
+		}
+	}
+}
+
+// pop will delete the node returned by f and return its copy. If others delete the node first, retry.
+// If f returns nil, return nil.
+func (s *lockFreeSkipList) pop(f func() *lockFreeNode) *Node {
+	previousNodes := make([]*lockFreeNode, s.level)
+	nextNodes := make([]*lockFreeNode, s.level)
+	for {
+		currentNode := f()
+		if currentNode == nil {
+			return nil
+		}
+
+		if value, ok := s.deleteNode(currentNode, previousNodes, nextNodes); ok {
+			return &Node{
+				index:     currentNode.index,
+				value:     value,
+				nextNodes: nil,
+			}
+		}
+	}
+}
+
+// first will return the first node of skip list.
+// If skip list is empty, return nil.
+func (s *lockFreeSkipList) first() *Node {
+	if currentNode := s.next(s.head); currentNode != nil {
+		return currentNode.toNode()
+	}
+
+	return nil
+}
+
+// last will return the last node of skip list.
+// If skip list is empty, return nil.
+func (s *lockFreeSkipList) last() *Node {
+	if currentNode := s.findLessOrEqual(math.MaxUint64); currentNode != s.head {
+		return currentNode.toNode()
+	}
+
+	return nil
+}
+
+// popFirst will remove the first node and return it.
+// If skip list is empty, return nil.
+func (s *lockFreeSkipList) popFirst() *Node {
+	return s.pop(func() *lockFreeNode {
+		return s.next(s.head)
+	})
+}
+
+// popLast will remove the last node and return it.
+// If skip list is empty, return nil.
+func (s *lockFreeSkipList) popLast() *Node {
+	return s.pop(func() *lockFreeNode {
+		if currentNode := s.findLessOrEqual(math.MaxUint64); currentNode != s.head {
+			return currentNode
+		}
+
+		return nil
+	})
+}
+
+// ceiling will return the first node whose index is >= given index.
+// If can not find, return nil.
+func (s *lockFreeSkipList) ceiling(index uint64) *Node {
+	if currentNode := s.findGreaterOrEqual(index); currentNode != nil {
+		return currentNode.toNode()
+	}
+
+	return nil
+}
+
+// floor will return the last node whose index is <= given index.
+// If can not find, return nil.
+func (s *lockFreeSkipList) floor(index uint64) *Node {
+	if currentNode := s.findLessOrEqual(index); currentNode != s.head {
+		return currentNode.toNode()
+	}
+
+	return nil
+}
+
+// rank will return the position of the node with given index by walking level 0.
+// If can not find the given index, return -1.
+func (s *lockFreeSkipList) rank(index uint64) int32 {
+	var rank int32
+	for currentNode := s.next(s.head); currentNode != nil && currentNode.index <= index; currentNode = s.next(currentNode) {
+		if currentNode.index == index {
+			return rank
+		}
+
+		rank++
+	}
+
+	return -1
+}
+
+// at will return the node at the given position by walking level 0.
+// If position is out of range, return nil.
+func (s *lockFreeSkipList) at(position int32) *Node {
+	if nodes := s.sub(position, 1); len(nodes) == 1 {
+		return nodes[0]
+	}
+
+	return nil
+}
+
+// snapshot will create a snapshot of the skip list and return a slice of the nodes.
+// The nodes inserted or deleted while iterating may or may not be contained.
+func (s *lockFreeSkipList) snapshot() []*Node {
+	result := make([]*Node, 0, s.getLength())
+	for currentNode := s.next(s.head); currentNode != nil; currentNode = s.next(currentNode) {
+		result = append(result, currentNode.toNode())
+	}
+
+	return result
+}
+
+// snapshotRange will create a snapshot of the nodes whose index is in [lo, hi).
+func (s *lockFreeSkipList) snapshotRange(lo, hi uint64) []*Node {
+	var result []*Node
+	for currentNode := s.findGreaterOrEqual(lo); currentNode != nil && currentNode.index < hi; currentNode = s.next(currentNode) {
+		result = append(result, currentNode.toNode())
+	}
+
+	return result
+}
+
+// scan will return copies of at most limit nodes whose index is in [lo, hi] in ascending order.
+func (s *lockFreeSkipList) scan(lo, hi uint64, limit int) []*Node {
+	var result []*Node
+	for currentNode := s.findGreaterOrEqual(lo); currentNode != nil && currentNode.index <= hi && len(result) < limit; currentNode = s.next(currentNode) {
+		result = append(result, currentNode.toNode())
+	}
+
+	return result
+}
+
+// scanReverse will return copies of at most limit nodes whose index is in [lo, hi] in descending order.
+// The lock-free skip list has no backward links, so each node is found from head.
+func (s *lockFreeSkipList) scanReverse(lo, hi uint64, limit int) []*Node {
+	var result []*Node
+	for len(result) < limit {
+		currentNode := s.findLessOrEqual(hi)
+		if currentNode == s.head || currentNode.index < lo {
+			break
+		}
+
+		result = append(result, currentNode.toNode())
+		if currentNode.index == 0 {
+			break
+		}
+
+		hi = currentNode.index - 1
+	}
+
+	return result
+}
+
+// snapshotReverse will create a snapshot of the skip list in descending order.
+// The lock-free skip list has no backward links, so it's the reverse of snapshot.
+func (s *lockFreeSkipList) snapshotReverse() []*Node {
+	return reverseNodes(s.snapshot())
+}
+
+// snapshotRangeReverse will create a snapshot of the nodes whose index is in [lo, hi) in descending order.
+func (s *lockFreeSkipList) snapshotRangeReverse(lo, hi uint64) []*Node {
+	return reverseNodes(s.snapshotRange(lo, hi))
+}
+
+// sub will skip the first startNumber nodes and return at most length nodes after them.
+func (s *lockFreeSkipList) sub(startNumber int32, length int32) []*Node {
+	if startNumber < 0 {
+		return nil
+	}
+
+	var result []*Node
+	currentNode := s.next(s.head)
+	for ; currentNode != nil && startNumber > 0; currentNode = s.next(currentNode) {
+		startNumber--
+	}
+
+	for ; currentNode != nil && int32(len(result)) < length; currentNode = s.next(currentNode) {
+		result = append(result, currentNode.toNode())
+	}
+
+	return result
+}
+
+// subReverse will skip the last startNumber nodes and return at most length nodes before them in descending order.
+func (s *lockFreeSkipList) subReverse(startNumber int32, length int32) []*Node {
+	nodes := s.snapshotReverse()
+	if startNumber < 0 || int(startNumber) >= len(nodes) {
+		return nil
+	}
+
+	nodes = nodes[startNumber:]
+	if int(length) < len(nodes) {
+		nodes = nodes[:length]
+	}
+
+	return nodes
+}
+
+// randomLevel will generate and random level that level > 0 and level < skip list's level
+// This comes from redis's implementation.
+func (s *lockFreeSkipList) randomLevel() int {
+	return randomLevel(s.level, s.probability, s.random)
+}
+
+// reverseNodes will reverse the given nodes in place and return it.
+func reverseNodes(nodes []*Node) []*Node {
+	for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	}
+
+	return nodes
+}
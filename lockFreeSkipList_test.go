@@ -0,0 +1,269 @@
+package ConcurrentSkipList
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestNewLockFreeConcurrentSkipList(t *testing.T) {
+	tests := []struct {
+		name  string
+		level int
+	}{
+		{"test1", -1},
+		{"test2", 64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := NewLockFreeConcurrentSkipList(tt.level); got != nil || err == nil {
+				t.Errorf("NewLockFreeConcurrentSkipList() = %#v,%#v", got, err)
+			}
+		})
+	}
+}
+
+func TestLockFreeConcurrentSkipList_Insert_Delete(t *testing.T) {
+	skipList, _ := NewLockFreeConcurrentSkipList(12)
+	for i := 0; i <= 10; i++ {
+		skipList.Insert(uint64(i), i)
+	}
+
+	skipList.Insert(uint64(5), 55)
+	skipList.Insert(uint64(math.MaxUint64), nil)
+	skipList.Delete(uint64(1))
+	skipList.Delete(uint64(11))
+
+	t.Run("test length", func(t *testing.T) {
+		if length := skipList.Length(); length != 10 {
+			t.Errorf("skip list's length is not correct, got %d", length)
+		}
+	})
+
+	tests := []struct {
+		name  string
+		index uint64
+		want  interface{}
+	}{
+		{"test1", 0, 0},
+		{"test2", 1, nil},
+		{"test3", 5, 55},
+		{"test4", 10, 10},
+		{"test5", math.MaxUint64, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := skipList.Search(tt.index)
+			if ok != (tt.want != nil) || (ok && got.Value() != tt.want) {
+				t.Errorf("Search() = %v, want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockFreeConcurrentSkipList_Parallel(t *testing.T) {
+	skipList, _ := NewLockFreeConcurrentSkipList(10)
+	count := 10000
+	indexes := make([]uint64, 0, count)
+	for i := 0; i < count; i++ {
+		indexes = append(indexes, Hash([]byte(strconv.Itoa(i))))
+	}
+
+	// Insert every index twice and delete the odd ones concurrently.
+	var wg sync.WaitGroup
+	for i, index := range indexes {
+		wg.Add(2)
+		go func(index uint64, v int) {
+			defer wg.Done()
+			skipList.Insert(index, v)
+		}(index, i)
+		go func(index uint64, v int) {
+			defer wg.Done()
+			skipList.Insert(index, v)
+		}(index, i)
+	}
+
+	wg.Wait()
+	t.Run("test length1", func(t *testing.T) {
+		if length := skipList.Length(); length != int32(count) {
+			t.Errorf("skip list's length are not correct, got %d", length)
+		}
+	})
+
+	for i := 1; i < count; i += 2 {
+		wg.Add(2)
+		go func(index uint64) {
+			defer wg.Done()
+			skipList.Delete(index)
+		}(indexes[i])
+		go func(index uint64) {
+			defer wg.Done()
+			skipList.Search(index)
+		}(indexes[i-1])
+	}
+
+	wg.Wait()
+	t.Run("test length2", func(t *testing.T) {
+		if length := skipList.Length(); length != int32(count/2) {
+			t.Errorf("skip list's length are not correct, got %d", length)
+		}
+	})
+
+	var want []uint64
+	for i := 0; i < count; i += 2 {
+		want = append(want, indexes[i])
+	}
+
+	sort.Slice(want, func(i, j int) bool {
+		return want[i] < want[j]
+	})
+
+	t.Run("test sequence", func(t *testing.T) {
+		i := 0
+		skipList.ForEach(func(node *Node) bool {
+			if node.Index() != want[i] {
+				t.Fatalf("ForEach() = %v, want = %v", node.Index(), want[i])
+			}
+
+			i++
+			return true
+		})
+
+		if i != len(want) {
+			t.Errorf("ForEach() count = %v, want = %v", i, len(want))
+		}
+	})
+
+	t.Run("test position", func(t *testing.T) {
+		for _, i := range []int{0, 100, len(want) - 1} {
+			if got, ok := skipList.Rank(want[i]); !ok || got != int32(i) {
+				t.Errorf("Rank() = %v, want = %v", got, i)
+			}
+
+			if got, ok := skipList.At(int32(i)); !ok || got.Index() != want[i] {
+				t.Errorf("At() = %v, want = %v", got, want[i])
+			}
+		}
+
+		if got := skipList.SubReverse(1, 2); len(got) != 2 || got[0].Index() != want[len(want)-2] {
+			t.Errorf("SubReverse() = %v, want = %v", got, want[len(want)-2])
+		}
+	})
+
+	t.Run("test navigation", func(t *testing.T) {
+		if got, ok := skipList.Ceiling(want[10] + 1); !ok || got.Index() != want[11] {
+			t.Errorf("Ceiling() = %v, want = %v", got, want[11])
+		}
+
+		if got, ok := skipList.Floor(want[10] - 1); !ok || got.Index() != want[9] {
+			t.Errorf("Floor() = %v, want = %v", got, want[9])
+		}
+
+		if got, ok := skipList.Last(); !ok || got.Index() != want[len(want)-1] {
+			t.Errorf("Last() = %v, want = %v", got, want[len(want)-1])
+		}
+	})
+
+	t.Run("test pop", func(t *testing.T) {
+		var mutex sync.Mutex
+		popped := make(map[uint64]bool)
+		for i := 0; i < len(want); i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				pop := skipList.PopFirst
+				if i%2 == 0 {
+					pop = skipList.PopLast
+				}
+
+				node, ok := pop()
+				if !ok {
+					t.Errorf("pop() should exist")
+					return
+				}
+
+				mutex.Lock()
+				defer mutex.Unlock()
+				if popped[node.Index()] {
+					t.Errorf("node %v is popped twice", node.Index())
+				}
+
+				popped[node.Index()] = true
+			}(i)
+		}
+
+		wg.Wait()
+		if len(popped) != len(want) || skipList.Length() != 0 {
+			t.Errorf("popped %d nodes, length %d", len(popped), skipList.Length())
+		}
+
+		if _, ok := skipList.First(); ok {
+			t.Errorf("First() on empty skip list should not exist")
+		}
+	})
+}
+
+func TestLockFreeConcurrentSkipList_Overwrite_Parallel(t *testing.T) {
+	skipList, _ := NewLockFreeConcurrentSkipList(8)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		for j := 0; j < 10; j++ {
+			wg.Add(1)
+			go func(index uint64, v string) {
+				defer wg.Done()
+				skipList.Insert(index, v)
+				if j%3 == 0 {
+					skipList.Delete(index)
+				}
+			}(uint64(i), fmt.Sprintf("%d-%d", i, j))
+		}
+	}
+
+	wg.Wait()
+	var length int32
+	skipList.ForEach(func(node *Node) bool {
+		length++
+		return true
+	})
+
+	t.Run("test length", func(t *testing.T) {
+		if length != skipList.Length() {
+			t.Errorf("skip list's length = %d, want %d", skipList.Length(), length)
+		}
+	})
+}
+
+func TestLockFreeSkipList_DeletedBeforeMarking(t *testing.T) {
+	s := newLockFreeSkipList(12, PROBABILITY, nil)
+	for _, index := range []uint64{0, 1, 2, 3} {
+		s.insert(index, index)
+	}
+
+	// Replace the values by tombstones without marking the links, like a deletion in progress.
+	for _, index := range []uint64{0, 2, 3} {
+		node := s.findGreaterOrEqual(index)
+		var tombstone interface{} = lockFreeTombstone{value: index}
+		node.value.Store(&tombstone)
+	}
+
+	for _, tt := range []struct {
+		name string
+		got  *Node
+		want uint64
+	}{
+		{"floor", s.floor(3), 1},
+		{"floor of deleted", s.floor(2), 1},
+		{"last", s.last(), 1},
+	} {
+		if tt.got == nil || tt.got.Index() != tt.want {
+			t.Errorf("%s() = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	if got := s.floor(0); got != nil {
+		t.Errorf("floor(0) = %v, want nil", got)
+	}
+}
@@ -0,0 +1,158 @@
+package ConcurrentSkipList
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+)
+
+// jsonSkipList is the JSON form of ConcurrentSkipList.
+type jsonSkipList struct {
+	Level   int         `json:"level"`
+	Entries []jsonEntry `json:"entries"`
+}
+
+// jsonEntry is the JSON form of a node.
+type jsonEntry struct {
+	Index uint64          `json:"index"`
+	Value json.RawMessage `json:"value"`
+}
+
+// jsonCodec will return the codec of values in JSON. If Options.Codec is a JSONCodec, it's used to decode
+// the values into their types, otherwise the values are decoded into interface{}.
+func (s *ConcurrentSkipList) jsonCodec() JSONCodec {
+	if codec, ok := s.codec.(JSONCodec); ok {
+		return codec
+	}
+
+	return JSONCodec{}
+}
+
+// binaryCodec will return the codec of values in binary form. The default codec is GobCodec.
+func (s *ConcurrentSkipList) binaryCodec() Codec {
+	if s.codec != nil {
+		return s.codec
+	}
+
+	return GobCodec{}
+}
+
+// initialize will initialize the zero value of skip list with given level and the default options,
+// so a skip list can be decoded into the zero value allocated by encoding/json or encoding/gob.
+func (s *ConcurrentSkipList) initialize(level int) error {
+	if s.loadTable() != nil {
+		return nil
+	}
+
+	return s.init(Options{MaxLevel: level})
+}
+
+// MarshalJSON will encode the skip list as an object with the level and the array of index and value
+// of each node in ascending order, for example {"level":12,"entries":[{"index":1,"value":"a"}]}.
+// Like WriteTo, the nodes are read from a snapshot. The zero value is encoded as {"level":0,"entries":[]}.
+// It implements json.Marshaler.
+func (s *ConcurrentSkipList) MarshalJSON() ([]byte, error) {
+	table, all, release := s.view()
+	defer release()
+
+	codec := s.jsonCodec()
+	result := jsonSkipList{Level: s.level, Entries: []jsonEntry{}}
+	for index, value := range all {
+		data, err := codec.Encode(value)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Entries = append(result.Entries, jsonEntry{Index: index, Value: data})
+	}
+
+	// The nodes are read shard by shard, so sort them if the shards are not ordered.
+	if table.partitioner != nil && !table.partitioner.Ordered() {
+		sort.Slice(result.Entries, func(i, j int) bool {
+			return result.Entries[i].Index < result.Entries[j].Index
+		})
+	}
+
+	return json.Marshal(result)
+}
+
+// UnmarshalJSON will replace the level and all nodes of the skip list by the JSON returned by MarshalJSON.
+// If the skip list is the zero value, it's initialized with the level and the default options.
+// Like ReadFrom, it should be called before the skip list is shared. It implements json.Unmarshaler.
+func (s *ConcurrentSkipList) UnmarshalJSON(data []byte) error {
+	var input jsonSkipList
+	if err := json.Unmarshal(data, &input); err != nil {
+		return err
+	}
+
+	if input.Level < 0 || input.Level > MAX_LEVEL {
+		return errors.New("invalid level, level must between 1 to 32")
+	}
+
+	// The zero value of skip list is encoded with level 0 and no entries, it removes all nodes and keeps the level.
+	if input.Level == 0 {
+		if len(input.Entries) > 0 {
+			return errors.New("invalid level, level must between 1 to 32")
+		}
+
+		if s.loadTable() == nil {
+			return nil
+		}
+
+		input.Level = s.level
+	}
+
+	if err := s.initialize(input.Level); err != nil {
+		return err
+	}
+
+	// The nodes are replaced without writing them, so the log can't replay them.
+	if s.wal != nil {
+		return errors.New("durable skip list can not be unmarshaled, use Open to load it")
+	}
+
+	codec := s.jsonCodec()
+	partitioner := s.loadTable().partitioner
+	groups := newEntryGroups(partitioner)
+	for _, entry := range input.Entries {
+		value, err := codec.Decode(entry.Value)
+		if err != nil {
+			return err
+		}
+
+		groups.add(entry.Index, value)
+	}
+
+	s.replace(input.Level, partitioner, groups.result())
+	return nil
+}
+
+// MarshalBinary will encode the skip list in the format of WriteTo. The values are encoded by Options.Codec,
+// the default codec is GobCodec. It implements encoding.BinaryMarshaler.
+func (s *ConcurrentSkipList) MarshalBinary() ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	if _, err := s.writeTo(buffer, s.binaryCodec()); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// UnmarshalBinary will replace the level and all nodes of the skip list by the data returned by MarshalBinary.
+// If the skip list is the zero value, it's initialized with the level and the default options, then the
+// layout of shards is restored like ReadFrom. The data of the zero value leaves the zero value unchanged. It implements encoding.BinaryUnmarshaler.
+func (s *ConcurrentSkipList) UnmarshalBinary(data []byte) error {
+	_, err := s.readFrom(bytes.NewReader(data), s.binaryCodec())
+	return err
+}
+
+// GobEncode is the same as MarshalBinary. It implements gob.GobEncoder.
+func (s *ConcurrentSkipList) GobEncode() ([]byte, error) {
+	return s.MarshalBinary()
+}
+
+// GobDecode is the same as UnmarshalBinary. It implements gob.GobDecoder.
+func (s *ConcurrentSkipList) GobDecode(data []byte) error {
+	return s.UnmarshalBinary(data)
+}
@@ -0,0 +1,210 @@
+package ConcurrentSkipList
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"testing"
+)
+
+type cached struct {
+	Name string
+	List *ConcurrentSkipList
+}
+
+func TestConcurrentSkipList_MarshalJSON(t *testing.T) {
+	skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 8, Shards: 4})
+	for _, index := range []uint64{shardIndexes[31], 3, 1, shardIndexes[0] + 2} {
+		skipList.Insert(index, "value")
+	}
+
+	data, err := json.Marshal(cached{Name: "cache", List: skipList})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"Name":"cache","List":{"level":8,"entries":[{"index":1,"value":"value"},{"index":3,"value":"value"},` +
+		`{"index":576460752303423489,"value":"value"},{"index":18446744073709551615,"value":"value"}]}}`
+	if string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+
+	var decoded cached
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.List.Level() != 8 || decoded.List.Length() != 4 {
+		t.Errorf("level = %d, length = %d, want 8, 4", decoded.List.Level(), decoded.List.Length())
+	}
+
+	equalEntries(t, decoded.List, skipList)
+
+	t.Run("test hash partitioner", func(t *testing.T) {
+		hash, _ := NewHashPartitioner(4)
+		hashed, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, Partitioner: hash})
+		for i := 0; i < 100; i++ {
+			hashed.Insert(uint64(i), i)
+		}
+
+		data, err := json.Marshal(hashed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var result jsonSkipList
+		json.Unmarshal(data, &result)
+		if len(result.Entries) != 100 {
+			t.Fatalf("Marshal() has %d entries, want 100", len(result.Entries))
+		}
+
+		for i, entry := range result.Entries {
+			if entry.Index != uint64(i) {
+				t.Fatalf("entry %d has index %d, the entries should be in ascending order", i, entry.Index)
+			}
+		}
+	})
+
+	t.Run("test codec", func(t *testing.T) {
+		points, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, Codec: JSONCodec{New: func() interface{} {
+			return &codecPoint{}
+		}}})
+		points.Insert(1, codecPoint{1, 2})
+		points.Insert(2, codecPoint{3, 4})
+
+		data, _ := json.Marshal(points)
+		points.Insert(3, codecPoint{5, 6})
+		if err := json.Unmarshal(data, points); err != nil {
+			t.Fatal(err)
+		}
+
+		if node, ok := points.Search(1); !ok || node.Value() != (codecPoint{1, 2}) || points.Length() != 2 {
+			t.Errorf("the values should be decoded by the codec, got %v", node.Value())
+		}
+	})
+
+	t.Run("test invalid", func(t *testing.T) {
+		var s ConcurrentSkipList
+		if err := json.Unmarshal([]byte(`{"level":33,"entries":[]}`), &s); err == nil {
+			t.Errorf("Unmarshal() should return an error for invalid level")
+		}
+
+		if err := json.Unmarshal([]byte(`{"level":12,"entries":[{"index":1,"value":}]}`), &s); err == nil {
+			t.Errorf("Unmarshal() should return an error for invalid JSON")
+		}
+	})
+}
+
+func TestConcurrentSkipList_MarshalBinary(t *testing.T) {
+	partitioner, _ := NewRangePartitioner(100)
+	skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 6, Partitioner: partitioner})
+	for i := 0; i < 200; i += 7 {
+		skipList.Insert(uint64(i), i)
+	}
+
+	data, err := skipList.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded ConcurrentSkipList
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Level() != 6 || len(decoded.loadTable().skipLists) != 2 {
+		t.Errorf("level = %d, shards = %d, want 6, 2", decoded.Level(), len(decoded.loadTable().skipLists))
+	}
+
+	equalEntries(t, &decoded, skipList)
+
+	t.Run("test gob", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		if err := gob.NewEncoder(buffer).Encode(cached{Name: "cache", List: skipList}); err != nil {
+			t.Fatal(err)
+		}
+
+		var decoded cached
+		if err := gob.NewDecoder(buffer).Decode(&decoded); err != nil {
+			t.Fatal(err)
+		}
+
+		if decoded.Name != "cache" || decoded.List.Level() != 6 {
+			t.Errorf("decoded %+v", decoded)
+		}
+
+		equalEntries(t, decoded.List, skipList)
+	})
+
+	t.Run("test corrupted", func(t *testing.T) {
+		if err := decoded.UnmarshalBinary(data[:len(data)-1]); err == nil {
+			t.Errorf("UnmarshalBinary() should return an error for corrupted data")
+		}
+	})
+}
+
+func TestConcurrentSkipList_MarshalZero(t *testing.T) {
+	t.Run("test json", func(t *testing.T) {
+		data, err := json.Marshal(&ConcurrentSkipList{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != `{"level":0,"entries":[]}` {
+			t.Errorf("Marshal() = %s", data)
+		}
+
+		decoded := &ConcurrentSkipList{}
+		if err := json.Unmarshal(data, decoded); err != nil {
+			t.Fatal(err)
+		}
+
+		if again, _ := json.Marshal(decoded); !bytes.Equal(again, data) {
+			t.Errorf("Marshal() after round trip = %s, want %s", again, data)
+		}
+
+		skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 8})
+		skipList.Insert(1, 1)
+		if err := json.Unmarshal(data, skipList); err != nil || skipList.Length() != 0 || skipList.Level() != 8 {
+			t.Errorf("Unmarshal() of zero value should remove all nodes, error = %v", err)
+		}
+	})
+
+	t.Run("test binary", func(t *testing.T) {
+		data, err := (&ConcurrentSkipList{}).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded := &ConcurrentSkipList{}
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+
+		if again, _ := decoded.MarshalBinary(); !bytes.Equal(again, data) {
+			t.Errorf("MarshalBinary() after round trip = %v, want %v", again, data)
+		}
+
+		skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 8})
+		skipList.Insert(1, 1)
+		if err := skipList.UnmarshalBinary(data); err != nil || skipList.Length() != 0 || skipList.Level() != 8 {
+			t.Errorf("UnmarshalBinary() of zero value should remove all nodes, error = %v", err)
+		}
+	})
+
+	t.Run("test gob", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		if err := gob.NewEncoder(buffer).Encode(&ConcurrentSkipList{}); err != nil {
+			t.Fatal(err)
+		}
+
+		decoded := &ConcurrentSkipList{}
+		if err := gob.NewDecoder(buffer).Decode(decoded); err != nil {
+			t.Fatal(err)
+		}
+
+		if decoded.loadTable() != nil {
+			t.Errorf("the zero value should stay the zero value")
+		}
+	})
+}
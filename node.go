@@ -1,26 +1,43 @@
 package ConcurrentSkipList
 
-type Node struct {
-	index     uint64
-	value     interface{}
-	nextNodes []*Node
+// Element is a node of skip list whose index and value are typed by K and V.
+type Element[K any, V any] struct {
+	index     K
+	value     V
+	nextNodes []*Element[K, V]
+	// spans[i] is the count of level 0 steps from this node to nextNodes[i].
+	// The span to tail counts the nodes after this node.
+	spans []int32
+	// previousNode is the backward link of level 0, the first node points to head.
+	previousNode *Element[K, V]
+	// access is the last access time or the count of accesses for EvictLRU and EvictLFU.
+	access uint64
 }
 
-// newNode will create a node using in this package but not external package.
-func newNode(index uint64, value interface{}, level int) *Node {
-	return &Node{
+// Node is the node of ConcurrentSkipList, whose index is uint64 and value is interface{}.
+type Node = Element[uint64, interface{}]
+
+// newElement will create an element using in this package but not external package.
+func newElement[K any, V any](index K, value V, level int) *Element[K, V] {
+	return &Element[K, V]{
 		index:     index,
 		value:     value,
-		nextNodes: make([]*Node, level, level),
+		nextNodes: make([]*Element[K, V], level, level),
+		spans:     make([]int32, level, level),
 	}
 }
 
+// copy will return a copy of the element without links.
+func (e *Element[K, V]) copy() *Element[K, V] {
+	return &Element[K, V]{index: e.index, value: e.value}
+}
+
 // Index will return the node's index.
-func (n *Node) Index() uint64 {
-	return n.index
+func (e *Element[K, V]) Index() K {
+	return e.index
 }
 
 // Value will return the node's value.
-func (n *Node) Value() interface{} {
-	return n.value
+func (e *Element[K, V]) Value() V {
+	return e.value
 }
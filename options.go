@@ -0,0 +1,213 @@
+package ConcurrentSkipList
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"math/rand"
+	"sync"
+)
+
+// Options is the configuration of NewConcurrentSkipListWithOptions.
+// The zero value of each field except MaxLevel means the default value.
+type Options struct {
+	// MaxLevel is the level of each shard, it must between 1 to 32.
+	// See NewConcurrentSkipList about how to determine it.
+	MaxLevel int
+
+	// Shards is the count of shards, it must be a power of two. The default value is SHARDS.
+	// The uint64 index space is split into Shards equal ranges, so fewer shards save the memory
+	// of heads and more shards reduce the contention of writers.
+	Shards int
+
+	// Probability is the probability of promoting a node to the next level, it must between 0 to 1.
+	// The default value is PROBABILITY.
+	Probability float64
+
+	// Source is the random source used to generate the level of nodes. The default source is the
+	// shared source of math/rand. Source is not required to be thread-safe, the access of it is serialized.
+	Source rand.Source
+
+	// LockFree selects lock-free shards, see NewLockFreeConcurrentSkipList.
+	LockFree bool
+
+	// Partitioner decides which shard an index belongs to. If it's set, Shards is ignored.
+	// The default partitioner splits the uint64 space into Shards equal ranges.
+	Partitioner Partitioner
+
+	// SplitLength is the length beyond which a shard is split into two at its median index.
+	// Zero disables splitting by length.
+	SplitLength int32
+
+	// SplitContention is the count of writes waiting for the lock of a shard beyond which the shard is split.
+	// The count is reset when it's checked. Zero disables splitting by contention.
+	SplitContention int64
+
+	// MergeLength is the length below which two neighbour shards are merged into one, it must be less than SplitLength.
+	// Zero disables merging. The shards split for contention are not merged in a second after splitting,
+	// so they are not merged back at once.
+	// Splitting and merging require lock-free disabled and an ordered partitioner with split points,
+	// they run in background while readers and writers continue. See ConcurrentSkipList.Balance.
+	MergeLength int32
+
+	// OnExpire is called with the index and value of each node removed after its TTL, see InsertWithTTL.
+	// It's called in the goroutine removing the node without holding the lock, it should return quickly.
+	OnExpire func(index uint64, value interface{})
+
+	// Capacity is the maximum length of skip list. After a write makes the length beyond it, the nodes
+	// chosen by Eviction are removed. Zero means no limit.
+	Capacity int32
+
+	// Eviction is the policy choosing the nodes to evict, the default policy is EvictLowest.
+	// EvictLRU and EvictLFU require lock-free disabled, as they sample the nodes by position.
+	Eviction EvictionPolicy
+
+	// OnEvict is called with the index and value of each evicted node after it's removed,
+	// in the goroutine of the write beyond the capacity and without holding the lock.
+	OnEvict func(index uint64, value interface{})
+
+	// Codec encodes and decodes the values when the skip list is written and read, see WriteTo and ReadFrom.
+	Codec Codec
+
+	// WatchBuffer is the size of the buffer of each watch. The default value is WATCH_BUFFER.
+	WatchBuffer int
+
+	// WatchPolicy decides what to do when the buffer of a watch is full, the default policy is WatchDrop.
+	WatchPolicy WatchPolicy
+}
+
+// NewConcurrentSkipListWithOptions will create a new concurrent skip list with given options.
+// If any option is invalid, will return an error.
+func NewConcurrentSkipListWithOptions(options Options) (*ConcurrentSkipList, error) {
+	s := &ConcurrentSkipList{}
+	if err := s.init(options); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// init will initialize the zero value of skip list with given options.
+// If any option is invalid, will return an error.
+func (s *ConcurrentSkipList) init(options Options) error {
+	if options.MaxLevel <= 0 || options.MaxLevel > MAX_LEVEL {
+		return errors.New("invalid level, level must between 1 to 32")
+	}
+
+	if options.Shards == 0 {
+		options.Shards = SHARDS
+	}
+
+	if options.Shards < 0 || options.Shards&(options.Shards-1) != 0 {
+		return errors.New("invalid shards, shards must be a power of two")
+	}
+
+	if options.Partitioner == nil {
+		options.Partitioner = newEqualRangePartitioner(options.Shards)
+	}
+
+	if options.Probability == 0 {
+		options.Probability = PROBABILITY
+	}
+
+	if options.Probability <= 0 || options.Probability >= 1 {
+		return errors.New("invalid probability, probability must between 0 to 1")
+	}
+
+	if options.SplitLength < 0 || options.SplitContention < 0 || options.MergeLength < 0 {
+		return errors.New("invalid thresholds, thresholds must not be negative")
+	}
+
+	if options.SplitLength > 0 && options.MergeLength >= options.SplitLength {
+		return errors.New("invalid thresholds, merge length must be less than split length")
+	}
+
+	if options.SplitLength > 0 || options.SplitContention > 0 || options.MergeLength > 0 {
+		if _, ok := options.Partitioner.(splitter); !ok || options.LockFree {
+			return errors.New("invalid thresholds, splitting and merging require lock-free disabled and an ordered partitioner with split points")
+		}
+	}
+
+	if options.Capacity < 0 {
+		return errors.New("invalid capacity, capacity must not be negative")
+	}
+
+	if options.Eviction < EvictLowest || options.Eviction > EvictLFU {
+		return errors.New("invalid eviction, eviction must be one of the eviction policies")
+	}
+
+	if (options.Eviction == EvictLRU || options.Eviction == EvictLFU) && options.LockFree {
+		return errors.New("invalid eviction, LRU and LFU require lock-free disabled")
+	}
+
+	if options.WatchBuffer == 0 {
+		options.WatchBuffer = WATCH_BUFFER
+	}
+
+	if options.WatchBuffer < 0 {
+		return errors.New("invalid watch buffer, watch buffer must be greater than 0")
+	}
+
+	if options.WatchPolicy < WatchDrop || options.WatchPolicy > WatchClose {
+		return errors.New("invalid watch policy, watch policy must be one of the watch policies")
+	}
+
+	var random *rand.Rand
+	if options.Source != nil {
+		random = rand.New(&lockedSource{source: options.Source})
+	}
+
+	s.level = options.MaxLevel
+	s.probability = options.Probability
+	s.random = random
+	s.lockFree = options.LockFree
+	s.splitLength = options.SplitLength
+	s.splitContention = options.SplitContention
+	s.mergeLength = options.MergeLength
+	s.onExpire = options.OnExpire
+	s.wake = make(chan struct{}, 1)
+	s.capacity = options.Capacity
+	s.eviction = options.Eviction
+	s.onEvict = options.OnEvict
+	s.codec = options.Codec
+	s.watchBuffer = options.WatchBuffer
+	s.watchPolicy = options.WatchPolicy
+	s.table.Store(s.newRoutingTable(options.Partitioner))
+	return nil
+}
+
+// newShardIndexes will split the uint64 space into given count of equal ranges and return the maximum index of each range.
+// The count must be a power of two.
+func newShardIndexes(shards int) []uint64 {
+	result := make([]uint64, shards)
+	shift := 64 - bits.TrailingZeros(uint(shards)) // step is 2^shift.
+	for i := 0; i < shards-1; i++ {
+		result[i] = uint64(i+1)<<shift - 1
+	}
+
+	result[shards-1] = math.MaxUint64
+	return result
+}
+
+// lockedSource is a rand.Source whose access is protected by a mutex,
+// so that it can be shared by shards and used by lock-free shards concurrently.
+type lockedSource struct {
+	mutex  sync.Mutex
+	source rand.Source
+}
+
+// Int63 implements rand.Source.
+func (l *lockedSource) Int63() int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.source.Int63()
+}
+
+// Seed implements rand.Source.
+func (l *lockedSource) Seed(seed int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.source.Seed(seed)
+}
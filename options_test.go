@@ -0,0 +1,143 @@
+package ConcurrentSkipList
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestNewConcurrentSkipListWithOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+	}{
+		{"test1", Options{}},
+		{"test2", Options{MaxLevel: 33}},
+		{"test3", Options{MaxLevel: 12, Shards: 3}},
+		{"test4", Options{MaxLevel: 12, Shards: -2}},
+		{"test5", Options{MaxLevel: 12, Probability: 1}},
+		{"test6", Options{MaxLevel: 12, Probability: -0.5}},
+		{"test7", Options{MaxLevel: 12, WatchBuffer: -1}},
+		{"test8", Options{MaxLevel: 12, WatchPolicy: WatchClose + 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := NewConcurrentSkipListWithOptions(tt.options); got != nil || err == nil {
+				t.Errorf("NewConcurrentSkipListWithOptions() = %#v,%#v", got, err)
+			}
+		})
+	}
+}
+
+func TestNewShardIndexes(t *testing.T) {
+	tests := []struct {
+		name   string
+		shards int
+		want   []uint64
+	}{
+		{"test1", 1, []uint64{math.MaxUint64}},
+		{"test2", 2, []uint64{1<<63 - 1, math.MaxUint64}},
+		{"test3", 4, []uint64{1<<62 - 1, 1<<63 - 1, 3<<62 - 1, math.MaxUint64}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newShardIndexes(tt.shards)
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("newShardIndexes() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	t.Run("test default", func(t *testing.T) {
+		var step uint64 = 1 << 59
+		for i, v := range shardIndexes {
+			if v != uint64(i+1)*step-1 {
+				t.Errorf("shardIndexes[%d] = %v", i, v)
+			}
+		}
+	})
+}
+
+func TestConcurrentSkipList_Options(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+	}{
+		{"test1 shard", Options{MaxLevel: 8, Shards: 1}},
+		{"test128 shards", Options{MaxLevel: 8, Shards: 128, Probability: 0.5}},
+		{"test source", Options{MaxLevel: 8, Source: rand.NewSource(1)}},
+		{"test lock-free", Options{MaxLevel: 8, Shards: 4, Source: rand.NewSource(1), LockFree: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skipList, err := NewConcurrentSkipListWithOptions(tt.options)
+			if err != nil {
+				t.Fatalf("NewConcurrentSkipListWithOptions() error %v", err)
+			}
+
+			if want := tt.options.Shards; want != 0 && len(skipList.loadTable().skipLists) != want {
+				t.Fatalf("shard count is not correct")
+			}
+
+			for i := 0; i < 1000; i++ {
+				skipList.Insert(Hash([]byte(strconv.Itoa(i))), i)
+			}
+			skipList.Insert(math.MaxUint64, 0)
+			skipList.Insert(0, 0)
+
+			if length := skipList.Length(); length != 1002 {
+				t.Errorf("skip list's length is not correct, got %d", length)
+			}
+
+			for i := 0; i < 1000; i++ {
+				if got, ok := skipList.Search(Hash([]byte(strconv.Itoa(i)))); !ok || got.Value() != i {
+					t.Fatalf("Search() = %v, want = %v", got, i)
+				}
+			}
+
+			var lastIndex uint64
+			count := 0
+			skipList.ForEach(func(node *Node) bool {
+				if count > 0 && lastIndex >= node.Index() {
+					t.Fatalf("incorrect sequence")
+				}
+
+				lastIndex = node.Index()
+				count++
+				return true
+			})
+
+			if got, ok := skipList.Last(); !ok || got.Index() != math.MaxUint64 {
+				t.Errorf("Last() = %v, want = %v", got, uint64(math.MaxUint64))
+			}
+		})
+	}
+}
+
+func TestConcurrentSkipList_Options_Source(t *testing.T) {
+	// The same source generates the same levels.
+	levels := func() []int {
+		concurrentSkipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, Shards: 1, Source: rand.NewSource(2018)})
+		for i := 0; i < 100; i++ {
+			concurrentSkipList.Insert(uint64(i), i)
+		}
+
+		var result []int
+		sl := concurrentSkipList.loadTable().skipLists[0].(*skipList)
+		for currentNode := sl.head.nextNodes[0]; currentNode != sl.tail; currentNode = currentNode.nextNodes[0] {
+			result = append(result, len(currentNode.nextNodes))
+		}
+
+		return result
+	}
+
+	want, got := levels(), levels()
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("level of node %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package ConcurrentSkipList
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Partitioner decides which shard an index belongs to.
+// A Partitioner must be immutable, the same index always belongs to the same shard.
+type Partitioner interface {
+	// Shards returns the count of shards.
+	Shards() int
+
+	// Shard returns which shard the index belongs to, it must between 0 to Shards()-1.
+	Shard(index uint64) int
+
+	// Ordered reports whether all indexes of a shard are less than the indexes of the next shard.
+	// If the shards are ordered, ForEach, Sub and the other functions iterate in global order,
+	// and Range, Ceiling and Floor only visit the shards they need.
+	// Otherwise, they iterate shard by shard and the indexes are only ordered in each shard.
+	Ordered() bool
+}
+
+// RangePartitioner is an ordered Partitioner which splits the uint64 space by split points.
+// Shard i contains the indexes in [splitPoints[i-1], splitPoints[i]).
+type RangePartitioner struct {
+	splitPoints []uint64
+}
+
+// NewRangePartitioner will create a range partitioner with given split points.
+// The split points must be ascending and greater than 0, len(splitPoints)+1 shards are created.
+// For example, split points 100 and 200 create 3 shards: [0, 100), [100, 200) and [200, math.MaxUint64].
+func NewRangePartitioner(splitPoints ...uint64) (*RangePartitioner, error) {
+	for i, p := range splitPoints {
+		if p == 0 || (i > 0 && p <= splitPoints[i-1]) {
+			return nil, errors.New("invalid split points, split points must be ascending and greater than 0")
+		}
+	}
+
+	return &RangePartitioner{
+		splitPoints: append([]uint64(nil), splitPoints...),
+	}, nil
+}
+
+// newEqualRangePartitioner will create a range partitioner splitting the uint64 space into given count of equal ranges.
+// The count must be a power of two.
+func newEqualRangePartitioner(shards int) *RangePartitioner {
+	indexes := newShardIndexes(shards)
+	splitPoints := make([]uint64, shards-1)
+	for i := range splitPoints {
+		splitPoints[i] = indexes[i] + 1
+	}
+
+	return &RangePartitioner{
+		splitPoints: splitPoints,
+	}
+}
+
+// Shards implements Partitioner.
+func (r *RangePartitioner) Shards() int {
+	return len(r.splitPoints) + 1
+}
+
+// Shard implements Partitioner.
+func (r *RangePartitioner) Shard(index uint64) int {
+	return sort.Search(len(r.splitPoints), func(i int) bool {
+		return index < r.splitPoints[i]
+	})
+}
+
+// Ordered implements Partitioner.
+func (r *RangePartitioner) Ordered() bool {
+	return true
+}
+
+// SplitPoints will return a copy of the split points.
+func (r *RangePartitioner) SplitPoints() []uint64 {
+	return append([]uint64(nil), r.splitPoints...)
+}
+
+// withSplitPoints will return a range partitioner with given split points.
+func (r *RangePartitioner) withSplitPoints(splitPoints []uint64) Partitioner {
+	return &RangePartitioner{
+		splitPoints: splitPoints,
+	}
+}
+
+// HashPartitioner is an unordered Partitioner which spreads indexes by their hash values.
+// Sequential indexes are spread to all shards, but the indexes are only ordered in each shard.
+type HashPartitioner struct {
+	shards int
+}
+
+// NewHashPartitioner will create a hash partitioner with given count of shards.
+// The count must be greater than 0.
+func NewHashPartitioner(shards int) (*HashPartitioner, error) {
+	if shards <= 0 {
+		return nil, errors.New("invalid shards, shards must be greater than 0")
+	}
+
+	return &HashPartitioner{
+		shards: shards,
+	}, nil
+}
+
+// Shards implements Partitioner.
+func (h *HashPartitioner) Shards() int {
+	return h.shards
+}
+
+// Shard implements Partitioner.
+// The index is mixed by the finalizer of MurmurHash3, which is much faster than Hash for a single uint64.
+func (h *HashPartitioner) Shard(index uint64) int {
+	index ^= index >> 33
+	index *= 0xff51afd7ed558ccd
+	index ^= index >> 33
+	index *= 0xc4ceb9fe1a85ec53
+	index ^= index >> 33
+	return int(index % uint64(h.shards))
+}
+
+// Ordered implements Partitioner.
+func (h *HashPartitioner) Ordered() bool {
+	return false
+}
+
+// AdaptivePartitioner is an ordered Partitioner which learns split points from observed indexes.
+// The inserted indexes are sampled by reservoir sampling. ConcurrentSkipList.Rebalance uses the
+// quantiles of samples as new split points, so each shard gets about the same count of indexes.
+// Before the first rebalancing, the uint64 space is split into equal ranges.
+type AdaptivePartitioner struct {
+	*RangePartitioner
+	shards  int
+	sampler *sampler
+}
+
+// NewAdaptivePartitioner will create an adaptive partitioner with given count of shards and sample size.
+// The count of shards must be a power of two and the sample size must be greater than 0.
+// Larger sample size learns more precise split points but costs more memory.
+func NewAdaptivePartitioner(shards int, sampleSize int) (*AdaptivePartitioner, error) {
+	if shards <= 0 || shards&(shards-1) != 0 {
+		return nil, errors.New("invalid shards, shards must be a power of two")
+	}
+
+	if sampleSize <= 0 {
+		return nil, errors.New("invalid sample size, sample size must be greater than 0")
+	}
+
+	return &AdaptivePartitioner{
+		RangePartitioner: newEqualRangePartitioner(shards),
+		shards:           shards,
+		sampler: &sampler{
+			samples: make([]uint64, 0, sampleSize),
+			random:  rand.New(rand.NewSource(rand.Int63())),
+		},
+	}, nil
+}
+
+// observe will sample the inserted index.
+func (a *AdaptivePartitioner) observe(index uint64) {
+	a.sampler.observe(index)
+}
+
+// learn will return a new adaptive partitioner whose split points are the quantiles of samples.
+// The new partitioner shares the samples with a. If there are no samples, return a.
+func (a *AdaptivePartitioner) learn() Partitioner {
+	samples := a.sampler.get()
+	if len(samples) == 0 {
+		return a
+	}
+
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i] < samples[j]
+	})
+
+	// Duplicated quantiles are merged, so the count of shards may be less than expected.
+	var splitPoints []uint64
+	for i := 1; i < a.shards; i++ {
+		p := samples[i*len(samples)/a.shards]
+		if p > 0 && (len(splitPoints) == 0 || p > splitPoints[len(splitPoints)-1]) {
+			splitPoints = append(splitPoints, p)
+		}
+	}
+
+	return a.withSplitPoints(splitPoints)
+}
+
+// withSplitPoints will return an adaptive partitioner with given split points.
+// The new partitioner shares the samples with a.
+func (a *AdaptivePartitioner) withSplitPoints(splitPoints []uint64) Partitioner {
+	return &AdaptivePartitioner{
+		RangePartitioner: &RangePartitioner{splitPoints: splitPoints},
+		shards:           a.shards,
+		sampler:          a.sampler,
+	}
+}
+
+// observer is implemented by the partitioners which need to observe the inserted indexes.
+type observer interface {
+	observe(index uint64)
+}
+
+// learner is implemented by the partitioners which can learn a new partitioner, see ConcurrentSkipList.Rebalance.
+type learner interface {
+	learn() Partitioner
+}
+
+// splitter is implemented by the ordered partitioners whose split points can be changed,
+// which is required to split and merge shards.
+type splitter interface {
+	SplitPoints() []uint64
+	withSplitPoints(splitPoints []uint64) Partitioner
+}
+
+// sampler keeps a uniform sample of the observed indexes, which comes from reservoir sampling.
+type sampler struct {
+	count   uint64
+	mutex   sync.Mutex
+	samples []uint64
+	random  *rand.Rand
+}
+
+// observe will add the index into samples with probability cap(samples)/count.
+// The lock is only held when the index is chosen, so the cost is an atomic add for most indexes.
+func (s *sampler) observe(index uint64) {
+	count := atomic.AddUint64(&s.count, 1)
+	if count > uint64(cap(s.samples)) && rand.Int63n(int64(count)) >= int64(cap(s.samples)) {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.samples) < cap(s.samples) {
+		s.samples = append(s.samples, index)
+	} else {
+		s.samples[s.random.Intn(len(s.samples))] = index
+	}
+}
+
+// get will return a copy of samples.
+func (s *sampler) get() []uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]uint64(nil), s.samples...)
+}
@@ -0,0 +1,316 @@
+package ConcurrentSkipList
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNewRangePartitioner(t *testing.T) {
+	tests := []struct {
+		name        string
+		splitPoints []uint64
+		wantErr     bool
+	}{
+		{"test1", []uint64{0}, true},
+		{"test2", []uint64{10, 10}, true},
+		{"test3", []uint64{20, 10}, true},
+		{"test4", nil, false},
+		{"test5", []uint64{10, 20}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRangePartitioner(tt.splitPoints...); (err != nil) != tt.wantErr {
+				t.Errorf("NewRangePartitioner() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRangePartitioner_Shard(t *testing.T) {
+	partitioner, _ := NewRangePartitioner(100, 200)
+	tests := []struct {
+		name  string
+		index uint64
+		want  int
+	}{
+		{"test1", 0, 0},
+		{"test2", 99, 0},
+		{"test3", 100, 1},
+		{"test4", 199, 1},
+		{"test5", 200, 2},
+		{"test6", math.MaxUint64, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := partitioner.Shard(tt.index); got != tt.want {
+				t.Errorf("Shard() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("test equal ranges", func(t *testing.T) {
+		equal := newEqualRangePartitioner(SHARDS)
+		for i, v := range shardIndexes {
+			if got := equal.Shard(v); got != i {
+				t.Errorf("Shard(%v) = %v, want %v", v, got, i)
+			}
+		}
+	})
+}
+
+func TestConcurrentSkipList_HashPartitioner(t *testing.T) {
+	partitioner, _ := NewHashPartitioner(8)
+	skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 8, Partitioner: partitioner})
+	count := 1000
+	for i := 1; i <= count; i++ {
+		skipList.Insert(uint64(i*10), i)
+	}
+
+	t.Run("test spread", func(t *testing.T) {
+		for i, sl := range skipList.loadTable().skipLists {
+			if l := sl.getLength(); l < int32(count/16) {
+				t.Errorf("shard %d has only %d nodes", i, l)
+			}
+		}
+	})
+
+	tests := []struct {
+		name  string
+		f     func(uint64) (*Node, bool)
+		index uint64
+		want  uint64
+	}{
+		{"test ceiling", skipList.Ceiling, 15, 20},
+		{"test floor", skipList.Floor, 15, 10},
+		{"test higher", skipList.Higher, 20, 30},
+		{"test lower", skipList.Lower, 20, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, ok := tt.f(tt.index); !ok || got.Index() != tt.want {
+				t.Errorf("got = %v, want = %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("test range", func(t *testing.T) {
+		var got []uint64
+		skipList.Range(100, 200, func(node *Node) bool {
+			got = append(got, node.Index())
+			return true
+		})
+
+		sort.Slice(got, func(i, j int) bool {
+			return got[i] < got[j]
+		})
+
+		if len(got) != 10 || got[0] != 100 || got[9] != 190 {
+			t.Errorf("Range() = %v", got)
+		}
+	})
+
+	t.Run("test pop", func(t *testing.T) {
+		if got, ok := skipList.First(); !ok || got.Index() != 10 {
+			t.Errorf("First() = %v, want = %v", got, 10)
+		}
+
+		for i := 1; i <= 10; i++ {
+			if got, ok := skipList.PopFirst(); !ok || got.Index() != uint64(i*10) {
+				t.Errorf("PopFirst() = %v, want = %v", got, i*10)
+			}
+
+			if got, ok := skipList.PopLast(); !ok || got.Index() != uint64((count+1-i)*10) {
+				t.Errorf("PopLast() = %v, want = %v", got, (count+1-i)*10)
+			}
+		}
+	})
+}
+
+func TestConcurrentSkipList_Rebalance(t *testing.T) {
+	partitioner, _ := NewAdaptivePartitioner(8, 1000)
+	skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 10, Partitioner: partitioner})
+	count := 10000
+	for i := 0; i < count; i++ {
+		skipList.Insert(uint64(i), i)
+	}
+
+	t.Run("test before", func(t *testing.T) {
+		if l := skipList.loadTable().skipLists[0].getLength(); l != int32(count) {
+			t.Errorf("sequential indexes should be in shard 0, got %d", l)
+		}
+	})
+
+	// Insert and delete while rebalancing.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := count + i; j < 2*count; j += 4 {
+				skipList.Insert(uint64(j), j)
+				skipList.Delete(uint64(j - count))
+			}
+		}(i)
+	}
+
+	if err := skipList.Rebalance(); err != nil {
+		t.Fatalf("Rebalance() error %v", err)
+	}
+
+	wg.Wait()
+	t.Run("test after", func(t *testing.T) {
+		table := skipList.loadTable()
+		if len(table.skipLists) < 2 {
+			t.Fatalf("shard count = %d after rebalance", len(table.skipLists))
+		}
+
+		if length := skipList.Length(); length != int32(count) {
+			t.Errorf("skip list's length is not correct, got %d", length)
+		}
+
+		i := count
+		skipList.ForEach(func(node *Node) bool {
+			if node.Index() != uint64(i) || node.Value() != i {
+				t.Fatalf("ForEach() = %v, want = %v", node.Index(), i)
+			}
+
+			i++
+			return true
+		})
+	})
+
+	t.Run("test balanced", func(t *testing.T) {
+		// Rebalance again by the indexes after the first rebalancing.
+		skipList.Rebalance()
+		for i, sl := range skipList.loadTable().skipLists {
+			if l := sl.getLength(); l > int32(count/2) {
+				t.Errorf("shard %d has %d nodes", i, l)
+			}
+		}
+	})
+
+	t.Run("test lock-free", func(t *testing.T) {
+		lockFreeSkipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 10, Partitioner: partitioner, LockFree: true})
+		if err := lockFreeSkipList.Rebalance(); err == nil {
+			t.Errorf("Rebalance() should return error for lock-free skip list")
+		}
+	})
+}
+
+func TestConcurrentSkipList_BalanceContention(t *testing.T) {
+	partitioner, _ := NewRangePartitioner()
+	list, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 10, Partitioner: partitioner, SplitContention: 10, MergeLength: 100})
+	for i := 0; i < 20; i++ {
+		list.Insert(uint64(i), i)
+	}
+
+	// A short shard split for contention is not merged back at once.
+	atomic.StoreInt64(&list.loadTable().skipLists[0].(*skipList).contention, 100)
+	list.Balance()
+	list.Balance()
+	if l := len(list.loadTable().skipLists); l != 2 {
+		t.Fatalf("shard count = %d after split for contention, want 2", l)
+	}
+
+	// The shards are merged after the cooldown.
+	for _, sl := range list.loadTable().skipLists {
+		sl.(*skipList).splitAt -= int64(mergeCooldown)
+	}
+
+	list.Balance()
+	if l := len(list.loadTable().skipLists); l != 1 || list.Length() != 20 {
+		t.Errorf("shard count = %d, length = %d after cooldown, want 1, 20", l, list.Length())
+	}
+}
+
+func TestConcurrentSkipList_Balance(t *testing.T) {
+	partitioner, _ := NewRangePartitioner()
+	skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 10, Partitioner: partitioner, SplitLength: 1000, MergeLength: 100})
+	count := 10000
+
+	// Insert while splitting in background.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := i; j < count; j += 4 {
+				skipList.Insert(uint64(j), j)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	skipList.Balance()
+	t.Run("test split", func(t *testing.T) {
+		table := skipList.loadTable()
+		if len(table.skipLists) < count/1000 {
+			t.Fatalf("shard count = %d after split", len(table.skipLists))
+		}
+
+		for i, sl := range table.skipLists {
+			if l := sl.getLength(); l > 1000 {
+				t.Errorf("shard %d has %d nodes", i, l)
+			}
+		}
+
+		if length := skipList.Length(); length != int32(count) {
+			t.Errorf("skip list's length is not correct, got %d", length)
+		}
+
+		i := 0
+		skipList.ForEach(func(node *Node) bool {
+			if node.Index() != uint64(i) || node.Value() != i {
+				t.Fatalf("ForEach() = %v, want = %v", node.Index(), i)
+			}
+
+			i++
+			return true
+		})
+	})
+
+	// Delete while merging in background.
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := i; j < count-10; j += 4 {
+				skipList.Delete(uint64(j))
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	skipList.Balance()
+	t.Run("test merge", func(t *testing.T) {
+		if l := len(skipList.loadTable().skipLists); l != 1 {
+			t.Errorf("shard count = %d after merge", l)
+		}
+
+		if length := skipList.Length(); length != 10 {
+			t.Errorf("skip list's length is not correct, got %d", length)
+		}
+
+		if got, ok := skipList.First(); !ok || got.Index() != uint64(count-10) {
+			t.Errorf("First() = %v, want = %v", got, count-10)
+		}
+	})
+
+	t.Run("test invalid options", func(t *testing.T) {
+		hash, _ := NewHashPartitioner(8)
+		options := []Options{
+			{MaxLevel: 10, SplitLength: -1},
+			{MaxLevel: 10, SplitLength: 100, MergeLength: 100},
+			{MaxLevel: 10, SplitLength: 100, Partitioner: hash},
+			{MaxLevel: 10, SplitContention: 100, LockFree: true},
+		}
+		for _, o := range options {
+			if _, err := NewConcurrentSkipListWithOptions(o); err == nil {
+				t.Errorf("NewConcurrentSkipListWithOptions(%+v) should return error", o)
+			}
+		}
+	})
+}
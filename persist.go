@@ -0,0 +1,475 @@
+package ConcurrentSkipList
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"iter"
+	"math"
+	"sort"
+)
+
+// The binary format of WriteTo is:
+//
+//	magic "CSKL" | version | level | layout | records | end | CRC-32C of all bytes before it
+//
+// The layout is a layout type followed by the split points for layoutRange or the count of shards
+// for layoutHash. Each record is recordEntry, the index and the length of encoded value as uvarint,
+// then the encoded value. The records of each shard are in ascending order.
+const (
+	persistMagic   = "CSKL"
+	persistVersion = 1
+)
+
+// The layout types of the shards.
+const (
+	layoutRange byte = iota
+	layoutHash
+	layoutOther
+)
+
+// The types of records.
+const (
+	recordEnd byte = iota
+	recordEntry
+)
+
+// castagnoli is the CRC-32C table used to checksum the persisted skip list.
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// encoder writes the persisted skip list and updates its checksum.
+// The first error is kept and the later writes do nothing.
+type encoder struct {
+	w        *bufio.Writer
+	checksum hash.Hash32
+	n        int64
+	err      error
+}
+
+// write will write the bytes.
+func (e *encoder) write(data []byte) {
+	if e.err != nil {
+		return
+	}
+
+	n, err := e.w.Write(data)
+	e.n += int64(n)
+	e.err = err
+	e.checksum.Write(data[:n])
+}
+
+// writeUvarint will write x as uvarint.
+func (e *encoder) writeUvarint(x uint64) {
+	var buffer [binary.MaxVarintLen64]byte
+	e.write(buffer[:binary.PutUvarint(buffer[:], x)])
+}
+
+// decoder reads the persisted skip list and updates its checksum.
+type decoder struct {
+	r        *bufio.Reader
+	checksum hash.Hash32
+	n        int64
+}
+
+// ReadByte implements io.ByteReader for binary.ReadUvarint.
+func (d *decoder) ReadByte() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+
+	d.n++
+	d.checksum.Write([]byte{b})
+	return b, nil
+}
+
+// read will read n bytes. The buffer grows with the read bytes, so a corrupted length doesn't allocate too much.
+func (d *decoder) read(n uint64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(d.r, int64(n)))
+	d.n += int64(len(data))
+	d.checksum.Write(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if uint64(len(data)) != n {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return data, nil
+}
+
+// readUvarint will read a uvarint.
+func (d *decoder) readUvarint() (uint64, error) {
+	return binary.ReadUvarint(d)
+}
+
+// unexpectedEOF will convert io.EOF to io.ErrUnexpectedEOF, as the data ends before the checksum.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// WriteTo will write the skip list to w in a versioned binary format with a checksum, and return the count
+// of written bytes. The header contains the level and the layout of shards, then the nodes are written
+// as sorted records and the values are encoded by Options.Codec. The TTL of nodes is not written.
+// The nodes are read from a snapshot, so they are consistent across shards. For lock-free shards, which
+// don't support snapshot, the nodes are read shard by shard like All.
+// It implements io.WriterTo.
+func (s *ConcurrentSkipList) WriteTo(w io.Writer) (int64, error) {
+	if s.codec == nil {
+		return 0, errors.New("invalid codec, codec is required to write skip list")
+	}
+
+	return s.writeTo(w, s.codec)
+}
+
+// view will return the routing table and the nodes of a consistent view of skip list, and the function
+// to release it. For lock-free shards, the nodes are read shard by shard like All.
+// The zero value of skip list has no routing table and no nodes.
+func (s *ConcurrentSkipList) view() (*routingTable, iter.Seq2[uint64, interface{}], func()) {
+	if s.loadTable() == nil {
+		return &routingTable{}, func(yield func(uint64, interface{}) bool) {}, func() {}
+	}
+
+	if !s.lockFree {
+		if snapshot, err := s.Snapshot(); err == nil {
+			return snapshot.table, snapshot.All(), snapshot.Release
+		}
+	}
+
+	return s.loadTable(), s.All(), func() {}
+}
+
+// writeTo is WriteTo with given codec.
+func (s *ConcurrentSkipList) writeTo(w io.Writer, codec Codec) (int64, error) {
+	table, all, release := s.view()
+	defer release()
+
+	e := &encoder{w: bufio.NewWriter(w), checksum: crc32.New(castagnoli)}
+	e.write([]byte(persistMagic))
+	e.write([]byte{persistVersion, byte(s.level)})
+	writeLayout(e, table.partitioner)
+	for index, value := range all {
+		data, err := codec.Encode(value)
+		if err != nil {
+			return e.n, err
+		}
+
+		e.write([]byte{recordEntry})
+		e.writeUvarint(index)
+		e.writeUvarint(uint64(len(data)))
+		e.write(data)
+		if e.err != nil {
+			return e.n, e.err
+		}
+	}
+
+	e.write([]byte{recordEnd})
+	e.write(binary.LittleEndian.AppendUint32(nil, e.checksum.Sum32()))
+	if e.err == nil {
+		e.err = e.w.Flush()
+	}
+
+	return e.n, e.err
+}
+
+// writeLayout will write the layout of shards of given partitioner.
+func writeLayout(e *encoder, partitioner Partitioner) {
+	switch p := partitioner.(type) {
+	case splitter:
+		splitPoints := p.SplitPoints()
+		e.write([]byte{layoutRange})
+		e.writeUvarint(uint64(len(splitPoints)))
+		for _, splitPoint := range splitPoints {
+			e.writeUvarint(splitPoint)
+		}
+	case *HashPartitioner:
+		e.write([]byte{layoutHash})
+		e.writeUvarint(uint64(p.Shards()))
+	default:
+		e.write([]byte{layoutOther})
+	}
+}
+
+// ReadFrom will read the skip list written by WriteTo from r and replace all nodes of the skip list,
+// and return the count of read bytes. The values are decoded by Options.Codec.
+// The level in the header replaces the level of skip list. The layout of shards in the header is restored
+// if it's the same kind as the partitioner of skip list: the split points replace the split points of
+// RangePartitioner or AdaptivePartitioner, and the count of shards replaces the one of HashPartitioner.
+// Otherwise the nodes are routed by the partitioner of skip list.
+// The nodes are appended to the shards in O(n) like FromSorted, and replace the nodes only if the data is
+// complete and the checksum matches, otherwise an error is returned and the skip list is not changed.
+// It should be called before the skip list is shared, as the level can't be changed concurrently.
+// The data of the zero value of skip list removes all nodes and keeps the level.
+// Durable skip lists are loaded by Open, so an error is returned for them.
+// It may read more bytes than the written data from r, as r is buffered. It implements io.ReaderFrom.
+func (s *ConcurrentSkipList) ReadFrom(r io.Reader) (int64, error) {
+	if s.codec == nil {
+		return 0, errors.New("invalid codec, codec is required to read skip list")
+	}
+
+	return s.readFrom(r, s.codec)
+}
+
+// readFrom is ReadFrom with given codec.
+func (s *ConcurrentSkipList) readFrom(r io.Reader, codec Codec) (int64, error) {
+	// The nodes are replaced without writing them, so the log can't replay them.
+	if s.wal != nil {
+		return 0, errors.New("durable skip list can not be read, use Open to load it")
+	}
+
+	d := &decoder{r: bufio.NewReader(r), checksum: crc32.New(castagnoli)}
+	header, err := d.read(uint64(len(persistMagic)) + 2)
+	if err != nil {
+		return d.n, err
+	}
+
+	if string(header[:len(persistMagic)]) != persistMagic {
+		return d.n, errors.New("invalid data, magic number does not match")
+	}
+
+	if version := header[len(persistMagic)]; version != persistVersion {
+		return d.n, fmt.Errorf("invalid data, version %d is not supported", version)
+	}
+
+	// The zero value of skip list is written with level 0 and no records.
+	level := int(header[len(persistMagic)+1])
+	if level > MAX_LEVEL {
+		return d.n, errors.New("invalid level, level must between 1 to 32")
+	}
+
+	if level > 0 {
+		if err := s.initialize(level); err != nil {
+			return d.n, err
+		}
+	} else {
+		level = s.level
+	}
+
+	var current Partitioner
+	if table := s.loadTable(); table != nil {
+		current = table.partitioner
+	}
+
+	partitioner, err := readLayout(d, current)
+	if err != nil {
+		return d.n, err
+	}
+
+	var entries map[int][]Entry
+	if level > 0 {
+		entries, err = readRecords(d, codec, partitioner)
+	} else if record, readErr := d.ReadByte(); readErr != nil {
+		err = readErr
+	} else if record != recordEnd {
+		err = errors.New("invalid data, skip list of level 0 must not have records")
+	}
+
+	if err != nil {
+		return d.n, err
+	}
+
+	sum := d.checksum.Sum32()
+	checksum, err := d.read(4)
+	if err != nil {
+		return d.n, unexpectedEOF(err)
+	}
+
+	if binary.LittleEndian.Uint32(checksum) != sum {
+		return d.n, errors.New("invalid data, checksum does not match")
+	}
+
+	// The zero value stays the zero value.
+	if level == 0 {
+		return d.n, nil
+	}
+
+	s.replace(level, partitioner, entries)
+	return d.n, nil
+}
+
+// replace will replace the level and all nodes of skip list by the entries grouped by the shard of given
+// partitioner. The entries of each shard are appended to the new shards in O(n), they must be sorted by index.
+// The skip list should not be shared, as the level can't be changed concurrently.
+func (s *ConcurrentSkipList) replace(level int, partitioner Partitioner, entries map[int][]Entry) {
+	s.rebalanceMutex.Lock()
+	defer s.rebalanceMutex.Unlock()
+
+	s.level = level
+	table := s.newRoutingTable(partitioner)
+	for i, sl := range table.skipLists {
+		b := sl.newBuilder()
+		for _, entry := range entries[i] {
+			b.append(entry.Index, entry.Value)
+		}
+
+		b.finish()
+	}
+
+	s.table.Store(table)
+	s.checkCapacity()
+}
+
+// entryGroups groups the entries by the shard of partitioner.
+// The shards are not created while grouping, so the entries are grouped in a map.
+type entryGroups struct {
+	partitioner Partitioner
+	entries     map[int][]Entry
+	sorted      bool
+}
+
+// newEntryGroups will create empty groups for given partitioner.
+func newEntryGroups(partitioner Partitioner) *entryGroups {
+	return &entryGroups{
+		partitioner: partitioner,
+		entries:     make(map[int][]Entry),
+		sorted:      true,
+	}
+}
+
+// add will add an entry to the group of its shard. Nil value is ignored like Insert.
+func (g *entryGroups) add(index uint64, value interface{}) {
+	if value == nil {
+		return
+	}
+
+	position := g.partitioner.Shard(index)
+	if l := len(g.entries[position]); l > 0 && g.entries[position][l-1].Index >= index {
+		g.sorted = false
+	}
+
+	g.entries[position] = append(g.entries[position], Entry{Index: index, Value: value})
+}
+
+// result will return the entries of each shard sorted by index, the last one of the same index is kept.
+func (g *entryGroups) result() map[int][]Entry {
+	// The entries of a shard may come from different shards, if the layout is not restored.
+	if !g.sorted {
+		for position, group := range g.entries {
+			sort.SliceStable(group, func(i, j int) bool {
+				return group[i].Index < group[j].Index
+			})
+
+			g.entries[position] = dedupEntries(group)
+		}
+	}
+
+	return g.entries
+}
+
+// readLayout will read the layout of shards and return the partitioner to restore it.
+// If the layout is not the same kind as given partitioner, return given partitioner.
+func readLayout(d *decoder, partitioner Partitioner) (Partitioner, error) {
+	layout, err := d.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch layout {
+	case layoutRange:
+		count, err := d.readUvarint()
+		if err != nil {
+			return nil, err
+		}
+
+		var splitPoints []uint64
+		for ; count > 0; count-- {
+			splitPoint, err := d.readUvarint()
+			if err != nil {
+				return nil, err
+			}
+
+			splitPoints = append(splitPoints, splitPoint)
+		}
+
+		// Validate the split points.
+		if _, err := NewRangePartitioner(splitPoints...); err != nil {
+			return nil, err
+		}
+
+		if p, ok := partitioner.(splitter); ok {
+			return p.withSplitPoints(splitPoints), nil
+		}
+	case layoutHash:
+		shards, err := d.readUvarint()
+		if err != nil {
+			return nil, err
+		}
+
+		if shards == 0 || shards > math.MaxInt32 {
+			return nil, errors.New("invalid shards, shards must be greater than 0")
+		}
+
+		if _, ok := partitioner.(*HashPartitioner); ok {
+			return NewHashPartitioner(int(shards))
+		}
+	case layoutOther:
+	default:
+		return nil, fmt.Errorf("invalid data, layout %d is not supported", layout)
+	}
+
+	return partitioner, nil
+}
+
+// readRecords will read the records until the end and group them by the shard of given partitioner.
+func readRecords(d *decoder, codec Codec, partitioner Partitioner) (map[int][]Entry, error) {
+	groups := newEntryGroups(partitioner)
+	for {
+		record, err := d.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		if record == recordEnd {
+			return groups.result(), nil
+		}
+
+		if record != recordEntry {
+			return nil, fmt.Errorf("invalid data, record %d is not supported", record)
+		}
+
+		index, err := d.readUvarint()
+		if err != nil {
+			return nil, err
+		}
+
+		length, err := d.readUvarint()
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := d.read(length)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := codec.Decode(data)
+		if err != nil {
+			return nil, err
+		}
+
+		groups.add(index, value)
+	}
+}
+
+// dedupEntries will keep the last entry of the same index in the sorted entries.
+func dedupEntries(entries []Entry) []Entry {
+	result := entries[:0]
+	for i, entry := range entries {
+		if i+1 < len(entries) && entries[i+1].Index == entry.Index {
+			continue
+		}
+
+		result = append(result, entry)
+	}
+
+	return result
+}
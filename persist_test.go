@@ -0,0 +1,135 @@
+package ConcurrentSkipList
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+)
+
+// intCodec encodes int values as decimal strings.
+type intCodec struct{}
+
+func (intCodec) Encode(value interface{}) ([]byte, error) {
+	i, ok := value.(int)
+	if !ok {
+		return nil, errors.New("value is not int")
+	}
+
+	return []byte(strconv.Itoa(i)), nil
+}
+
+func (intCodec) Decode(data []byte) (interface{}, error) {
+	return strconv.Atoi(string(data))
+}
+
+func TestConcurrentSkipList_WriteTo(t *testing.T) {
+	hash, _ := NewHashPartitioner(8)
+	ranges, _ := NewRangePartitioner(100, 1000)
+	for _, options := range []Options{
+		{MaxLevel: 12, Codec: intCodec{}},
+		{MaxLevel: 8, Codec: intCodec{}, Partitioner: ranges},
+		{MaxLevel: 12, Codec: intCodec{}, Partitioner: hash},
+		{MaxLevel: 12, Codec: intCodec{}, LockFree: true},
+	} {
+		skipList, _ := NewConcurrentSkipListWithOptions(options)
+		for i := 0; i < 2000; i += 3 {
+			skipList.Insert(uint64(i), i)
+		}
+
+		buffer := &bytes.Buffer{}
+		n, err := skipList.WriteTo(buffer)
+		if err != nil || n != int64(buffer.Len()) {
+			t.Fatalf("WriteTo() = %d, %v, written %d bytes", n, err, buffer.Len())
+		}
+
+		data := buffer.Bytes()
+		restored, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 4, Codec: intCodec{}, Partitioner: options.Partitioner, LockFree: options.LockFree})
+		restored.Insert(1, 1)
+		if n, err := restored.ReadFrom(bytes.NewReader(data)); err != nil || n != int64(len(data)) {
+			t.Fatalf("ReadFrom() = %d, %v, want %d", n, err, len(data))
+		}
+
+		if restored.Level() != options.MaxLevel || restored.Length() != skipList.Length() {
+			t.Errorf("level = %d, length = %d, want %d, %d", restored.Level(), restored.Length(), options.MaxLevel, skipList.Length())
+		}
+
+		if len(restored.loadTable().skipLists) != len(skipList.loadTable().skipLists) {
+			t.Errorf("the layout of shards is not restored")
+		}
+
+		for i := 0; i < 2000; i++ {
+			node, ok := restored.Search(uint64(i))
+			if ok != (i%3 == 0) || (ok && node.Value() != i) {
+				t.Fatalf("Search(%d) = %v, %v", i, node, ok)
+			}
+		}
+	}
+}
+
+func TestConcurrentSkipList_ReadFrom(t *testing.T) {
+	skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, Shards: 4, Codec: intCodec{}})
+	for i := 0; i < 100; i++ {
+		skipList.Insert(uint64(i)<<60, i)
+	}
+
+	buffer := &bytes.Buffer{}
+	if _, err := skipList.WriteTo(buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buffer.Bytes()
+	t.Run("test other layout", func(t *testing.T) {
+		hash, _ := NewHashPartitioner(2)
+		restored, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, Partitioner: hash, Codec: intCodec{}})
+		if _, err := restored.ReadFrom(bytes.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(restored.loadTable().skipLists) != 2 || restored.Length() != skipList.Length() {
+			t.Errorf("the nodes should be routed by the partitioner of skip list")
+		}
+	})
+
+	t.Run("test corrupted", func(t *testing.T) {
+		restored, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, Codec: intCodec{}})
+		restored.Insert(1, 1)
+
+		corrupted := append([]byte(nil), data...)
+		corrupted[len(corrupted)/2] ^= 0xff
+		if _, err := restored.ReadFrom(bytes.NewReader(corrupted)); err == nil {
+			t.Errorf("ReadFrom() should return an error for corrupted data")
+		}
+
+		if _, err := restored.ReadFrom(bytes.NewReader(data[:len(data)-2])); err != io.ErrUnexpectedEOF {
+			t.Errorf("ReadFrom() error = %v, want %v", err, io.ErrUnexpectedEOF)
+		}
+
+		wrongVersion := append([]byte(nil), data...)
+		wrongVersion[len(persistMagic)] = persistVersion + 1
+		if _, err := restored.ReadFrom(bytes.NewReader(wrongVersion)); err == nil {
+			t.Errorf("ReadFrom() should return an error for unsupported version")
+		}
+
+		if restored.Length() != 1 {
+			t.Errorf("the skip list should not be changed")
+		}
+	})
+
+	t.Run("test codec", func(t *testing.T) {
+		noCodec, _ := NewConcurrentSkipList(12)
+		if _, err := noCodec.WriteTo(io.Discard); err == nil {
+			t.Errorf("WriteTo() should return an error without codec")
+		}
+
+		if _, err := noCodec.ReadFrom(bytes.NewReader(data)); err == nil {
+			t.Errorf("ReadFrom() should return an error without codec")
+		}
+
+		skipList.Insert(1, "string")
+		if _, err := skipList.WriteTo(io.Discard); err == nil {
+			t.Errorf("WriteTo() should return the error of codec")
+		}
+	})
+}
@@ -0,0 +1,330 @@
+package ConcurrentSkipList
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// mergeCooldown is the time after splitting for contention during which the new shards are not merged.
+// A shard split for contention may be shorter than MergeLength, without the cooldown it's merged back at once
+// and the split does nothing but copying the shard twice.
+const mergeCooldown = time.Second
+
+// routingTable routes indexes to shards by a partitioner. It's immutable and replaced as a whole
+// when the shards are rebalanced, so an operation loads the routing table once and uses it consistently.
+type routingTable struct {
+	partitioner Partitioner
+	skipLists   []shard
+}
+
+// router locates the shard which the given index belongs to now.
+// A retired shard forwards the writes to the shard returned by its router.
+type router interface {
+	shardFor(index uint64) shard
+}
+
+// best will return the least or greatest node returned by f() of all shards.
+// It's used to find a node in global order when the shards are not ordered.
+func (t *routingTable) best(f func(sl shard) *Node, least bool) (*Node, bool) {
+	var result *Node
+	for _, sl := range t.skipLists {
+		if sl.getLength() == 0 {
+			continue
+		}
+
+		if node := f(sl); node != nil && (result == nil || (node.index < result.index) == least) {
+			result = node
+		}
+	}
+
+	return result, result != nil
+}
+
+// shardRange will return the first and last shard overlapping [lo, hi).
+// If the shards are not ordered, all shards may overlap.
+func (t *routingTable) shardRange(lo, hi uint64) (int, int) {
+	if !t.partitioner.Ordered() {
+		return 0, len(t.skipLists) - 1
+	}
+
+	return t.partitioner.Shard(lo), t.partitioner.Shard(hi - 1)
+}
+
+// loadTable will return the current routing table.
+func (s *ConcurrentSkipList) loadTable() *routingTable {
+	return s.table.Load()
+}
+
+// shardFor will return the shard which the given index belongs to in the current routing table.
+func (s *ConcurrentSkipList) shardFor(index uint64) shard {
+	table := s.loadTable()
+	return table.skipLists[table.partitioner.Shard(index)]
+}
+
+// newShard will create an empty shard with the options of skip list.
+func (s *ConcurrentSkipList) newShard() shard {
+	if s.lockFree {
+		return newLockFreeSkipList(s.level, s.probability, s.random)
+	}
+
+	sl := newSkipList(s.level, s.probability, s.random)
+	sl.router = s
+	sl.versions = &s.versions
+	sl.onExpire = s.onExpire
+	sl.wal = s.wal
+	sl.watch = &s.watch
+	if s.eviction == EvictLRU || s.eviction == EvictLFU {
+		sl.touch = s.touch
+	}
+	return sl
+}
+
+// newRoutingTable will create a routing table with empty shards for given partitioner.
+func (s *ConcurrentSkipList) newRoutingTable(partitioner Partitioner) *routingTable {
+	skipLists := make([]shard, partitioner.Shards())
+	for i := range skipLists {
+		skipLists[i] = s.newShard()
+	}
+
+	return &routingTable{
+		partitioner: partitioner,
+		skipLists:   skipLists,
+	}
+}
+
+// Rebalance will redistribute the nodes by the split points learned from the inserted indexes.
+// Only AdaptivePartitioner learns split points, for other partitioners Rebalance does nothing.
+// While rebalancing, all shards are locked and the nodes are copied into new shards, then the new
+// shards replace the old ones. The writers blocked by the old shards are forwarded to the new shards,
+// and the readers blocked by the old shards read the nodes as before rebalancing.
+// Lock-free shards can't be locked, so they can't be rebalanced and an error is returned.
+func (s *ConcurrentSkipList) Rebalance() error {
+	if s.lockFree {
+		return errors.New("lock-free skip list can not be rebalanced")
+	}
+
+	s.rebalanceMutex.Lock()
+	defer s.rebalanceMutex.Unlock()
+
+	table := s.loadTable()
+	l, ok := table.partitioner.(learner)
+	if !ok {
+		return nil
+	}
+
+	s.repartition(table, l.learn())
+	return nil
+}
+
+// repartition will move all nodes of table into the new shards of given partitioner and replace table.
+// The caller must hold the rebalanceMutex.
+func (s *ConcurrentSkipList) repartition(table *routingTable, partitioner Partitioner) {
+	// Lock the old shards in order and never unlock until they are retired.
+	for _, sl := range table.skipLists {
+		sl.(*skipList).mutex.Lock()
+	}
+
+	// The learned partitioners are ordered, so the nodes are visited in ascending order of the new shards too
+	// and appended to them directly. The nodes are not changed, so the writes are not logged again.
+	newTable := s.newRoutingTable(partitioner)
+	builders := make([]*skipListBuilder, len(newTable.skipLists))
+	for i, sl := range newTable.skipLists {
+		builders[i] = sl.newBuilder().(*skipListBuilder)
+	}
+
+	for _, sl := range table.skipLists {
+		old := sl.(*skipList)
+		for currentNode := old.head.nextNodes[0]; currentNode != old.tail; currentNode = currentNode.nextNodes[0] {
+			builders[partitioner.Shard(currentNode.index)].appendNode(currentNode)
+		}
+
+		copyDeadlines(old, func(index uint64) shard {
+			return newTable.skipLists[partitioner.Shard(index)]
+		})
+	}
+
+	for _, b := range builders {
+		b.finish()
+	}
+
+	s.table.Store(newTable)
+	for _, sl := range table.skipLists {
+		old := sl.(*skipList)
+		old.retired = true
+		old.mutex.Unlock()
+	}
+}
+
+// Balance will split the shards whose length or contention is beyond the thresholds and merge the
+// neighbour shards whose total length is below the threshold, until no shard needs to be changed.
+// See Options about the thresholds. Balance is called in background automatically when a threshold
+// is reached, it's exported for the caller who wants to balance immediately.
+// Only the shards being split or merged are locked, the other shards continue serving. Like Rebalance,
+// the writers blocked by the old shards are forwarded to the new shards.
+func (s *ConcurrentSkipList) Balance() {
+	s.rebalanceMutex.Lock()
+	defer s.rebalanceMutex.Unlock()
+
+	for s.balanceOnce() {
+	}
+}
+
+// balanceOnce will split or merge at most one shard and return whether the routing table is changed.
+// The caller must hold the rebalanceMutex.
+func (s *ConcurrentSkipList) balanceOnce() bool {
+	table := s.loadTable()
+	if _, ok := table.partitioner.(splitter); !ok || s.lockFree {
+		return false
+	}
+
+	for i, sl := range table.skipLists {
+		l := sl.getLength()
+		contention := atomic.SwapInt64(&sl.(*skipList).contention, 0)
+		long := s.splitLength > 0 && l > s.splitLength
+		if l >= 2 && (long || (s.splitContention > 0 && contention > s.splitContention)) {
+			s.split(table, i)
+			if newTable := s.loadTable(); !long && newTable != table {
+				now := time.Now().UnixNano()
+				newTable.skipLists[i].(*skipList).splitAt = now
+				newTable.skipLists[i+1].(*skipList).splitAt = now
+			}
+
+			return true
+		}
+
+		if s.mergeLength > 0 && i+1 < len(table.skipLists) && l+table.skipLists[i+1].getLength() < s.mergeLength &&
+			!cooling(sl) && !cooling(table.skipLists[i+1]) {
+			s.merge(table, i)
+			return true
+		}
+	}
+
+	return false
+}
+
+// cooling will return whether the shard was split for contention within mergeCooldown, so it must not be merged yet.
+// The caller must hold the rebalanceMutex.
+func cooling(sl shard) bool {
+	splitAt := sl.(*skipList).splitAt
+	return splitAt != 0 && time.Now().UnixNano()-splitAt < int64(mergeCooldown)
+}
+
+// balanceAsync will start Balance in background if it's not running.
+func (s *ConcurrentSkipList) balanceAsync() {
+	if !atomic.CompareAndSwapInt32(&s.balancing, 0, 1) {
+		return
+	}
+
+	go func() {
+		defer atomic.StoreInt32(&s.balancing, 0)
+		s.Balance()
+	}()
+}
+
+// checkSplit will start balancing if the given shard needs to be split.
+func (s *ConcurrentSkipList) checkSplit(sl shard) {
+	if (s.splitLength > 0 && sl.getLength() > s.splitLength) ||
+		(s.splitContention > 0 && atomic.LoadInt64(&sl.(*skipList).contention) > s.splitContention) {
+		s.balanceAsync()
+	}
+}
+
+// checkMerge will start balancing if the given shard may need to be merged.
+func (s *ConcurrentSkipList) checkMerge(sl shard) {
+	if s.mergeLength > 0 && sl.getLength() < s.mergeLength && len(s.loadTable().skipLists) > 1 {
+		s.balanceAsync()
+	}
+}
+
+// split will split the i-th shard of table into two at its median index and replace table.
+// The caller must hold the rebalanceMutex.
+func (s *ConcurrentSkipList) split(table *routingTable, i int) {
+	old := table.skipLists[i].(*skipList)
+	old.mutex.Lock()
+	defer old.mutex.Unlock()
+
+	// The median index is greater than the first index of the shard, so it's a valid split point.
+	median := old.findByPosition(old.length / 2)
+	if median == old.tail {
+		return
+	}
+
+	// The nodes are visited in ascending order, so append them to the new shards directly.
+	left, right := s.newShard(), s.newShard()
+	leftBuilder, rightBuilder := left.newBuilder().(*skipListBuilder), right.newBuilder().(*skipListBuilder)
+	for currentNode := old.head.nextNodes[0]; currentNode != old.tail; currentNode = currentNode.nextNodes[0] {
+		if currentNode.index < median.index {
+			leftBuilder.appendNode(currentNode)
+		} else {
+			rightBuilder.appendNode(currentNode)
+		}
+	}
+
+	leftBuilder.finish()
+	rightBuilder.finish()
+	copyDeadlines(old, func(index uint64) shard {
+		if index < median.index {
+			return left
+		}
+
+		return right
+	})
+
+	p := table.partitioner.(splitter)
+	splitPoints := p.SplitPoints()
+	splitPoints = append(splitPoints[:i], append([]uint64{median.index}, splitPoints[i:]...)...)
+
+	skipLists := make([]shard, 0, len(table.skipLists)+1)
+	skipLists = append(skipLists, table.skipLists[:i]...)
+	skipLists = append(skipLists, left, right)
+	skipLists = append(skipLists, table.skipLists[i+1:]...)
+
+	s.table.Store(&routingTable{
+		partitioner: p.withSplitPoints(splitPoints),
+		skipLists:   skipLists,
+	})
+	old.retired = true
+}
+
+// merge will merge the i-th and (i+1)-th shard of table into one and replace table.
+// The caller must hold the rebalanceMutex.
+func (s *ConcurrentSkipList) merge(table *routingTable, i int) {
+	left, right := table.skipLists[i].(*skipList), table.skipLists[i+1].(*skipList)
+	left.mutex.Lock()
+	defer left.mutex.Unlock()
+	right.mutex.Lock()
+	defer right.mutex.Unlock()
+
+	// The nodes of left are less than the nodes of right, so append them to the new shard directly.
+	merged := s.newShard()
+	mergedBuilder := merged.newBuilder().(*skipListBuilder)
+	for _, old := range []*skipList{left, right} {
+		for currentNode := old.head.nextNodes[0]; currentNode != old.tail; currentNode = currentNode.nextNodes[0] {
+			mergedBuilder.appendNode(currentNode)
+		}
+	}
+
+	mergedBuilder.finish()
+	for _, old := range []*skipList{left, right} {
+		copyDeadlines(old, func(uint64) shard {
+			return merged
+		})
+	}
+
+	p := table.partitioner.(splitter)
+	splitPoints := p.SplitPoints()
+	splitPoints = append(splitPoints[:i], splitPoints[i+1:]...)
+
+	skipLists := make([]shard, 0, len(table.skipLists)-1)
+	skipLists = append(skipLists, table.skipLists[:i]...)
+	skipLists = append(skipLists, merged)
+	skipLists = append(skipLists, table.skipLists[i+2:]...)
+
+	s.table.Store(&routingTable{
+		partitioner: p.withSplitPoints(splitPoints),
+		skipLists:   skipLists,
+	})
+	left.retired = true
+	right.retired = true
+}
@@ -0,0 +1,153 @@
+package ConcurrentSkipList
+
+import (
+	"iter"
+	"math"
+)
+
+// streamBatchSize is the count of nodes copied from a shard at a time when streaming.
+const streamBatchSize = 64
+
+// All will return an iterator of the index and value of each node in ascending order.
+// The nodes are copied from shards batch by batch, no lock is held while yielding, so the loop body
+// may modify the skip list. Like Iterator, each batch starts after the last yielded index in the
+// live skip list, so the nodes inserted ahead are visited and the nodes deleted ahead are not.
+// If the shards are not ordered, the nodes are yielded shard by shard and only ordered in each shard.
+func (s *ConcurrentSkipList) All() iter.Seq2[uint64, interface{}] {
+	return func(yield func(uint64, interface{}) bool) {
+		s.stream(0, math.MaxUint64, true, func(node *Node) bool {
+			return yield(node.index, node.value)
+		})
+	}
+}
+
+// Backward will return an iterator of the index and value of each node in descending order.
+// See All about the consistency.
+func (s *ConcurrentSkipList) Backward() iter.Seq2[uint64, interface{}] {
+	return func(yield func(uint64, interface{}) bool) {
+		s.stream(0, math.MaxUint64, false, func(node *Node) bool {
+			return yield(node.index, node.value)
+		})
+	}
+}
+
+// RangeSeq will return an iterator of the index and value of each node whose index is in [lo, hi)
+// in ascending order, it's the iterator version of Range. See All about the consistency.
+func (s *ConcurrentSkipList) RangeSeq(lo, hi uint64) iter.Seq2[uint64, interface{}] {
+	return func(yield func(uint64, interface{}) bool) {
+		// Ignore empty range.
+		if lo >= hi {
+			return
+		}
+
+		s.stream(lo, hi-1, true, func(node *Node) bool {
+			return yield(node.index, node.value)
+		})
+	}
+}
+
+// Keys will return an iterator of the indexes in ascending order. See All about the consistency.
+func (s *ConcurrentSkipList) Keys() iter.Seq[uint64] {
+	return func(yield func(uint64) bool) {
+		s.stream(0, math.MaxUint64, true, func(node *Node) bool {
+			return yield(node.index)
+		})
+	}
+}
+
+// Values will return an iterator of the values in ascending order of indexes. See All about the consistency.
+func (s *ConcurrentSkipList) Values() iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		s.stream(0, math.MaxUint64, true, func(node *Node) bool {
+			return yield(node.value)
+		})
+	}
+}
+
+// stream will call f() with the nodes whose index is in [lo, hi] until f() return false.
+// If the shards are ordered, each batch is routed by the current routing table, so a rebalancing while
+// streaming is followed. Otherwise, the shards of the routing table at the beginning are streamed one by one.
+func (s *ConcurrentSkipList) stream(lo, hi uint64, ascending bool, f func(node *Node) bool) {
+	table := s.loadTable()
+	if table.partitioner.Ordered() {
+		streamBatches(lo, hi, ascending, func(lo, hi uint64) []*Node {
+			return s.nextBatch(lo, hi, ascending)
+		}, f)
+
+		return
+	}
+
+	for _, sl := range table.skipLists {
+		if sl.getLength() == 0 {
+			continue
+		}
+
+		if !streamBatches(lo, hi, ascending, func(lo, hi uint64) []*Node {
+			return scanShard(sl, lo, hi, ascending, streamBatchSize)
+		}, f) {
+			return
+		}
+	}
+}
+
+// nextBatch will return at most streamBatchSize nodes whose index is in [lo, hi] in the order of streaming.
+// The shards must be ordered.
+func (s *ConcurrentSkipList) nextBatch(lo, hi uint64, ascending bool) []*Node {
+	table := s.loadTable()
+	first, last := table.partitioner.Shard(lo), table.partitioner.Shard(hi)
+	var batch []*Node
+	for i := first; i <= last && len(batch) < streamBatchSize; i++ {
+		sl := table.skipLists[i]
+		if !ascending {
+			sl = table.skipLists[first+last-i]
+		}
+
+		if sl.getLength() != 0 {
+			batch = append(batch, scanShard(sl, lo, hi, ascending, streamBatchSize-len(batch))...)
+		}
+	}
+
+	return batch
+}
+
+// scanShard will return at most limit nodes of sl whose index is in [lo, hi] in the order of streaming.
+func scanShard(sl shard, lo, hi uint64, ascending bool, limit int) []*Node {
+	if ascending {
+		return sl.scan(lo, hi, limit)
+	}
+
+	return sl.scanReverse(lo, hi, limit)
+}
+
+// streamBatches will call f() with the nodes returned by next() batch by batch. Each batch starts
+// after the last node of the previous batch, and a batch with less than streamBatchSize nodes is the last one.
+// If f() return false, stop and return false.
+func streamBatches(lo, hi uint64, ascending bool, next func(lo, hi uint64) []*Node, f func(node *Node) bool) bool {
+	for {
+		batch := next(lo, hi)
+		for _, node := range batch {
+			if !f(node) {
+				return false
+			}
+		}
+
+		if len(batch) < streamBatchSize {
+			return true
+		}
+
+		last := batch[len(batch)-1].index
+		if ascending {
+			if last == hi {
+				return true
+			}
+
+			lo = last + 1
+		} else {
+			if last == lo {
+				return true
+			}
+
+			hi = last - 1
+		}
+	}
+}
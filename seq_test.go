@@ -0,0 +1,122 @@
+package ConcurrentSkipList
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestConcurrentSkipList_Seq(t *testing.T) {
+	hash, _ := NewHashPartitioner(4)
+	tests := []struct {
+		name    string
+		options Options
+	}{
+		{"locked", Options{MaxLevel: 12}},
+		{"lock-free", Options{MaxLevel: 12, LockFree: true}},
+		{"hash", Options{MaxLevel: 12, Partitioner: hash}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skipList, _ := NewConcurrentSkipListWithOptions(tt.options)
+			var want []uint64
+			for i := 0; i < 1000; i++ {
+				want = append(want, uint64(i*7))
+			}
+
+			want = append(want, shardIndexes[3], shardIndexes[3]+1, math.MaxUint64)
+			for _, v := range want {
+				skipList.Insert(v, v)
+			}
+
+			ordered := skipList.loadTable().partitioner.Ordered()
+			check := func(t *testing.T, got []uint64, want []uint64, ascending bool) {
+				// The unordered shards are yielded one by one, so sort before comparing.
+				if !ordered {
+					sort.Slice(got, func(i, j int) bool {
+						return (got[i] < got[j]) == ascending
+					})
+				}
+
+				if len(got) != len(want) {
+					t.Fatalf("got %d indexes, want %d", len(got), len(want))
+				}
+
+				for i := range got {
+					if got[i] != want[i] {
+						t.Fatalf("got[%d] = %v, want %v", i, got[i], want[i])
+					}
+				}
+			}
+
+			t.Run("test all", func(t *testing.T) {
+				var got []uint64
+				for k, v := range skipList.All() {
+					if v != k {
+						t.Fatalf("value of %v = %v", k, v)
+					}
+
+					got = append(got, k)
+				}
+
+				check(t, got, want, true)
+			})
+
+			t.Run("test backward", func(t *testing.T) {
+				var got []uint64
+				for k := range skipList.Backward() {
+					got = append(got, k)
+				}
+
+				reversed := slices.Clone(want)
+				slices.Reverse(reversed)
+				check(t, got, reversed, false)
+			})
+
+			t.Run("test range", func(t *testing.T) {
+				var got []uint64
+				for k := range skipList.RangeSeq(70, 7000) {
+					got = append(got, k)
+				}
+
+				check(t, got, want[10:1000], true)
+
+				for range skipList.RangeSeq(10, 10) {
+					t.Fatalf("empty range should yield nothing")
+				}
+			})
+
+			t.Run("test keys and values", func(t *testing.T) {
+				var keys []uint64
+				for k := range skipList.Keys() {
+					keys = append(keys, k)
+				}
+
+				var values []uint64
+				for v := range skipList.Values() {
+					values = append(values, v.(uint64))
+				}
+
+				check(t, keys, want, true)
+				check(t, values, want, true)
+			})
+
+			t.Run("test break and modify", func(t *testing.T) {
+				count := 0
+				for k := range skipList.All() {
+					// No lock is held while yielding.
+					skipList.Delete(k)
+					count++
+					if count == 100 {
+						break
+					}
+				}
+
+				if length := skipList.Length(); length != int32(len(want)-100) {
+					t.Errorf("skip list's length is not correct, got %d", length)
+				}
+			})
+		})
+	}
+}
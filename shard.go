@@ -0,0 +1,77 @@
+package ConcurrentSkipList
+
+// shard is a skip list holding the indexes of one shard of ConcurrentSkipList.
+// skipList protects a shard with a read-write lock and lockFreeSkipList updates a shard by CAS.
+// Except getLength, the methods are safe to call concurrently and handle their own synchronization.
+type shard interface {
+	// getLength will return the length of the shard.
+	getLength() int32
+
+	// search will return the node with given index. If can not find the given index, return nil.
+	search(index uint64) *Node
+
+	// insert will insert a value into the shard. If the index exists, overwrite the value.
+	// Return the previous value and whether the index existed.
+	insert(index uint64, value interface{}) (interface{}, bool)
+
+	// delete will delete the node with given index if existed.
+	// Return the deleted value and whether the index existed.
+	delete(index uint64) (interface{}, bool)
+
+	// compute will call f() with the value of given index and whether it exists, then store the returned
+	// value if keep is true and value is not nil, otherwise delete the index. The read and the write are atomic.
+	// Return the value after computing and whether it exists.
+	compute(index uint64, f func(old interface{}, loaded bool) (value interface{}, keep bool)) (interface{}, bool)
+
+	// insertBatch and deleteBatch insert or delete the entries or indexes sorted by index.
+	// If the shard is retired before the batch is applied, return false and nothing is changed.
+	insertBatch(entries []Entry) bool
+	deleteBatch(indexes []uint64) bool
+
+	// first and last will return the first and last node of the shard. If the shard is empty, return nil.
+	first() *Node
+	last() *Node
+
+	// popFirst and popLast will remove the first and last node of the shard and return it.
+	// If the shard is empty, return nil.
+	popFirst() *Node
+	popLast() *Node
+
+	// ceiling will return a copy of the first node whose index is >= given index, floor will return
+	// a copy of the last node whose index is <= given index. If can not find, return nil.
+	ceiling(index uint64) *Node
+	floor(index uint64) *Node
+
+	// rank will return the position of given index in the shard, or -1 if can not find.
+	rank(index uint64) int32
+
+	// at will return the node at given position of the shard, or nil if out of range.
+	at(position int32) *Node
+
+	// The snapshot functions return copies of the nodes in ascending or descending order.
+	snapshot() []*Node
+	snapshotRange(lo, hi uint64) []*Node
+	snapshotReverse() []*Node
+	snapshotRangeReverse(lo, hi uint64) []*Node
+	sub(startNumber int32, length int32) []*Node
+	subReverse(startNumber int32, length int32) []*Node
+
+	// scan and scanReverse return copies of at most limit nodes whose index is in [lo, hi]
+	// in ascending or descending order. They are used to stream a shard batch by batch.
+	scan(lo, hi uint64, limit int) []*Node
+	scanReverse(lo, hi uint64, limit int) []*Node
+
+	// newBuilder will return a builder appending nodes to the empty shard.
+	// The shard must not be accessed by others until the builder finishes.
+	newBuilder() builder
+}
+
+// builder appends nodes in ascending order of index to an empty shard in O(1) for each node.
+// The caller must guarantee the order.
+type builder interface {
+	// append will add a node after the last node.
+	append(index uint64, value interface{})
+
+	// finish will link the last nodes to tail and update the length.
+	finish()
+}
@@ -1,169 +1,321 @@
 package ConcurrentSkipList
 
 import (
+	"cmp"
 	"math/rand"
 	"sync"
 	"sync/atomic"
 )
 
+// skipList is a shard of ConcurrentSkipList. It's the list of uint64 indexes and interface{} values
+// with the features of ConcurrentSkipList, which are notified of the writes of list by changed and accessed.
 type skipList struct {
-	level  int
-	length int32
-	head   *Node
-	tail   *Node
-	mutex  sync.RWMutex
+	list[uint64, interface{}]
+	// retired means the nodes have been moved to other shards. It's protected by mutex.
+	// The writes of a retired skip list are forwarded to the shard returned by router.
+	retired bool
+	router  router
+	// contention is the count of writes which waited for the lock.
+	contention int64
+	// splitAt is the time in nanoseconds when the shard was created by splitting for contention, 0 means it's not.
+	// It's protected by the rebalanceMutex of ConcurrentSkipList.
+	splitAt int64
+	// versions is shared by the shards of a ConcurrentSkipList. sequence is the sequence number of the write
+	// holding the lock, 0 means no snapshot is active. undo keeps the values overwritten by the writes
+	// for the snapshots, see Snapshot. undoIndexes is the indexes of undo in ascending order, so the snapshots
+	// merge the changes in a range with the nodes without sorting the whole undo log.
+	versions    *versions
+	sequence    uint64
+	undo        map[uint64][]undoEntry
+	undoIndexes []uint64
+	// deadlines is the expiration time in unix nanoseconds of the indexes inserted with TTL, and expirations
+	// orders them by deadline. nextDeadline is the earliest deadline, 0 means no index will expire.
+	// onExpire is called with the expired indexes and values, see Options.OnExpire.
+	deadlines    map[uint64]int64
+	expirations  expirationQueue
+	nextDeadline int64
+	onExpire     func(index uint64, value interface{})
+	// touch records an access of a node, nil means the eviction policy doesn't track accesses.
+	touch func(node *Node)
+	// wal is the write-ahead log of the writes, nil means the skip list is not durable.
+	wal *wal
+	// records collects the log records of the transaction committing on the shard, see Txn.commit.
+	records *[][]byte
+	// watch is shared by the shards of a ConcurrentSkipList. events are the changes waiting to be published
+	// and emitted means the write holding the lock has changes, see unlock.
+	watch        *watchers
+	events       []Event
+	emitted      bool
+	eventsMutex  sync.Mutex
+	publishMutex sync.Mutex
 }
 
 // newSkipList will create a concurrent skip list with given level.
-func newSkipList(level int) *skipList {
-	head := newNode(0, nil, level)
-	var tail *Node
-	for i := 0; i < len(head.nextNodes); i++ {
-		head.nextNodes[i] = tail
-	}
+// The level of nodes is promoted with given probability using given random.
+func newSkipList(level int, probability float64, random *rand.Rand) *skipList {
+	s := &skipList{}
+	s.init(level, probability, random, cmp.Compare[uint64])
+	s.hooks = s
+	return s
+}
 
-	return &skipList{
-		level:  level,
-		length: 0,
-		head:   head,
-		tail:   tail,
-	}
+// search will return the value whose index is given index.
+// If can not find the given index, return nil.
+func (s *skipList) search(index uint64) *Node {
+	// Read lock and unlock.
+	s.rlock()
+	defer s.mutex.RUnlock()
+
+	return s.find(index)
 }
 
-// searchWithPreviousNode will search given index in skip list.
-// The first return value represents the previous nodes need to update when call Insert function.
-// The second return value represents the value with given index or the closet value whose index is larger than given index.
-func (s *skipList) searchWithPreviousNodes(index uint64) ([]*Node, *Node) {
-	// Store all previous value whose index is less than index and whose next value's index is larger than index.
-	previousNodes := make([]*Node, s.level)
+// lockIndex will acquire the write lock before writing given index.
+// If the skip list is retired, release the lock and return the shard which the index belongs to now,
+// otherwise return nil and the caller must release the lock.
+func (s *skipList) lockIndex(index uint64) shard {
+	s.expire()
+	if s.lock() {
+		return nil
+	}
 
-	// fmt.Printf("start doSearch:%v\n", index)
-	currentNode := s.head
+	return s.router.shardFor(index)
+}
 
-	// Iterate from top level to bottom level.
-	for l := s.level - 1; l >= 0; l-- {
-		// Iterate value util value's index is >= given index.
-		// The max iterate count is skip list's length. So the worst O(n) is N.
-		for currentNode.nextNodes[l] != s.tail && currentNode.nextNodes[l].index < index {
-			currentNode = currentNode.nextNodes[l]
-		}
+// skipListBuilder appends nodes to an empty shard of ConcurrentSkipList without searching.
+type skipListBuilder = listBuilder[uint64, interface{}]
 
-		// When next value's index is >= given index, add current value whose index < given index.
-		previousNodes[l] = currentNode
+// newBuilder will return a builder appending nodes to the empty skip list.
+func (s *skipList) newBuilder() builder {
+	return s.newListBuilder()
+}
+
+// lock will acquire the write lock and count the contention.
+// If the skip list is retired, release the lock and return false.
+func (s *skipList) lock() bool {
+	if !s.mutex.TryLock() {
+		atomic.AddInt64(&s.contention, 1)
+		s.mutex.Lock()
 	}
 
-	// Avoid point to tail which will occur panic in Insert and Delete function.
-	// When the next value is tail.
-	// The index is larger than the maximum index in the skip list or skip list's length is 0. Don't point to tail.
-	// When the next value isn't tail.
-	// Next value's index must >= given index. Point to it.
-	if currentNode.nextNodes[0] != s.tail {
-		currentNode = currentNode.nextNodes[0]
+	if !s.retired {
+		s.sequence = s.versions.next()
+		return true
 	}
-	// fmt.Printf("previous value:\n")
-	// for _, n := range previousNodes {
-	// 	fmt.Printf("%p\t", n)
-	// }
-	// fmt.Println()
-	// fmt.Printf("end doSearch %v\n", index)
 
-	return previousNodes, currentNode
+	s.mutex.Unlock()
+	return false
 }
 
-// searchWithoutPreviousNodes will return the value whose index is given index.
-// If can not find the given index, return nil.
-// This function is faster than searchWithPreviousNodes and it used to only searching index.
-func (s *skipList) searchWithoutPreviousNodes(index uint64) *Node {
-	currentNode := s.head
+// insertBatch will insert the entries sorted by index while holding the write lock once.
+// Each index is searched from the previous nodes of the last one, so sorted entries are inserted in about linear time.
+// If the skip list is retired, return false and the caller should route the entries again.
+func (s *skipList) insertBatch(entries []Entry) bool {
+	s.expire()
+	if !s.lock() {
+		return false
+	}
+	defer s.unlock()
+
+	previousNodes, ranks := s.newFingers()
+	fingers := make([]*Node, s.level)
+	for i, entry := range entries {
+		// The fingers must be less than the index, so only the last entry of the same index is inserted.
+		if i+1 < len(entries) && entries[i+1].Index == entry.Index {
+			continue
+		}
 
-	// Read lock and unlock.
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
+		nextNode := s.searchFrom(entry.Index, previousNodes, ranks)
+		if nextNode != s.tail && nextNode.index == entry.Index {
+			s.set(nextNode, entry.Value)
+			continue
+		}
 
-	// Iterate from top level to bottom level.
-	for l := s.level - 1; l >= 0; l-- {
-		// Iterate value util value's index is >= given index.
-		// The max iterate count is skip list's length. So the worst O(n) is N.
-		for currentNode.nextNodes[l] != s.tail && currentNode.nextNodes[l].index < index {
-			currentNode = currentNode.nextNodes[l]
+		// link releases the previous nodes, so keep the fingers and move them to the new node.
+		copy(fingers, previousNodes)
+		rank := ranks[0] + 1
+		newNode := s.link(previousNodes, ranks, entry.Index, entry.Value)
+		for i := range fingers {
+			if i < len(newNode.nextNodes) {
+				previousNodes[i], ranks[i] = newNode, rank
+			} else {
+				previousNodes[i] = fingers[i]
+			}
 		}
 	}
 
-	currentNode = currentNode.nextNodes[0]
-	if currentNode == s.tail || currentNode.index > index {
-		return nil
-	} else if currentNode.index == index {
-		return currentNode
-	} else {
-		return nil
+	return true
+}
+
+// deleteBatch will delete the sorted indexes while holding the write lock once.
+// Like insertBatch, each index is searched from the previous nodes of the last one.
+// If the skip list is retired, return false and the caller should route the indexes again.
+func (s *skipList) deleteBatch(indexes []uint64) bool {
+	s.expire()
+	if !s.lock() {
+		return false
 	}
+	defer s.unlock()
+
+	previousNodes, ranks := s.newFingers()
+	fingers := make([]*Node, s.level)
+	for _, index := range indexes {
+		nextNode := s.searchFrom(index, previousNodes, ranks)
+		if nextNode == s.tail || nextNode.index != index {
+			continue
+		}
+
+		// unlink releases the previous nodes, but they are still the fingers of the next index.
+		copy(fingers, previousNodes)
+		s.unlink(previousNodes, nextNode)
+		copy(previousNodes, fingers)
+	}
+
+	return true
 }
 
 // insert will insert a value into skip list and update the length.
-// If skip has these this index, overwrite the value, otherwise add it.
-func (s *skipList) insert(index uint64, value interface{}) {
+// If skip has these this index, overwrite the value and return the previous value and true, otherwise add it.
+func (s *skipList) insert(index uint64, value interface{}) (interface{}, bool) {
+	// Write lock and unlock.
+	if sl := s.lockIndex(index); sl != nil {
+		return sl.insert(index, value)
+	}
+	defer s.unlock()
+
+	return s.insertLocked(index, value)
+}
+
+// changed will be called before each write of the index with its value before and after the write,
+// nil means the index doesn't exist. The old value is kept for the snapshots, the TTL of the index is
+// dropped, the write is appended to the write-ahead log and recorded for the watches.
+// The caller must hold the write lock.
+func (s *skipList) changed(index uint64, old, value interface{}) {
+	s.record(index, old)
+	s.dropDeadline(index)
+	if s.records != nil {
+		s.wal.collect(s.records, index, value)
+	} else if s.wal != nil {
+		s.wal.append(index, value)
+	}
+
+	s.emit(index, old, value)
+}
+
+// delete will find the index is existed or not firstly.
+// If existed, delete it, update length and return the deleted value and true, otherwise do nothing.
+func (s *skipList) delete(index uint64) (interface{}, bool) {
 	// Write lock and unlock.
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	if sl := s.lockIndex(index); sl != nil {
+		return sl.delete(index)
+	}
+	defer s.unlock()
 
-	previousNodes, currentNode := s.searchWithPreviousNodes(index)
+	return s.deleteLocked(index)
+}
+
+// compute will call f() with the value of given index under the write lock, then store the returned
+// value if keep is true or delete the index otherwise. Return the value after computing and whether it exists.
+func (s *skipList) compute(index uint64, f func(old interface{}, loaded bool) (interface{}, bool)) (interface{}, bool) {
+	// Write lock and unlock.
+	if sl := s.lockIndex(index); sl != nil {
+		return sl.compute(index, f)
+	}
+	defer s.unlock()
 
+	previousNodes, ranks, currentNode := s.searchWithPreviousNodes(index)
 	if currentNode != s.head && currentNode.index == index {
-		currentNode.value = value
-		return
+		value, keep := f(currentNode.value, true)
+		if keep && value != nil {
+			s.set(currentNode, value)
+			return value, true
+		}
+
+		s.unlink(previousNodes, currentNode)
+		return nil, false
 	}
 
-	// Make a new value.
-	newNode := newNode(index, value, s.randomLevel())
+	value, keep := f(nil, false)
+	if !keep || value == nil {
+		return nil, false
+	}
 
-	// Adjust pointer. Similar to update linked list.
-	for i := len(newNode.nextNodes) - 1; i >= 0; i-- {
-		// Firstly, new value point to next value.
-		newNode.nextNodes[i] = previousNodes[i].nextNodes[i]
+	s.link(previousNodes, ranks, index, value)
+	return value, true
+}
 
-		// Secondly, previous nodes point to new value.
-		previousNodes[i].nextNodes[i] = newNode
+// first will return a copy of the first node of skip list.
+// The node is copied under the read lock, so its value doesn't change with the later writes.
+// If skip list is empty, return nil.
+func (s *skipList) first() *Node {
+	s.rlock()
+	defer s.mutex.RUnlock()
 
-		// Finally, in order to release the slice, point to nil.
-		previousNodes[i] = nil
+	if currentNode := s.head.nextNodes[0]; currentNode != s.tail {
+		return currentNode.copy()
 	}
 
-	atomic.AddInt32(&s.length, 1)
+	return nil
+}
 
-	for i := len(newNode.nextNodes); i < len(previousNodes); i++ {
-		previousNodes[i] = nil
+// last will return a copy of the last node of skip list.
+// If skip list is empty, return nil.
+func (s *skipList) last() *Node {
+	s.rlock()
+	defer s.mutex.RUnlock()
+
+	if currentNode := s.findLast(); currentNode != s.head {
+		return currentNode.copy()
 	}
+
+	return nil
 }
 
-// delete will find the index is existed or not firstly.
-// If existed, delete it and update length, otherwise do nothing.
-func (s *skipList) delete(index uint64) {
-	// Write lock and unlock.
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+// popFirst will remove the first node and return it.
+// If skip list is empty or retired, return nil.
+func (s *skipList) popFirst() *Node {
+	s.expire()
+	if !s.lock() {
+		return nil
+	}
+	defer s.unlock()
 
-	previousNodes, currentNode := s.searchWithPreviousNodes(index)
+	currentNode := s.head.nextNodes[0]
+	if currentNode == s.tail {
+		return nil
+	}
 
-	// If skip list length is 0 or could not find value with the given index.
-	if currentNode != s.head && currentNode.index == index {
-		// Adjust pointer. Similar to update linked list.
-		for i := 0; i < len(currentNode.nextNodes); i++ {
-			previousNodes[i].nextNodes[i] = currentNode.nextNodes[i]
-			currentNode.nextNodes[i] = nil
-			previousNodes[i] = nil
-		}
+	// All previous nodes of the first node are head.
+	previousNodes := make([]*Node, s.level)
+	for i := range previousNodes {
+		previousNodes[i] = s.head
+	}
 
-		atomic.AddInt32(&s.length, -1)
+	s.unlink(previousNodes, currentNode)
+	return currentNode
+}
+
+// popLast will remove the last node and return it.
+// If skip list is empty or retired, return nil.
+func (s *skipList) popLast() *Node {
+	s.expire()
+	if !s.lock() {
+		return nil
 	}
+	defer s.unlock()
 
-	for i := len(currentNode.nextNodes); i < len(previousNodes); i++ {
-		previousNodes[i] = nil
+	currentNode := s.findLast()
+	if currentNode == s.head {
+		return nil
 	}
+
+	previousNodes, _, _ := s.searchWithPreviousNodes(currentNode.index)
+	s.unlink(previousNodes, currentNode)
+	return currentNode
 }
 
 // snapshot will create a snapshot of the skip list and return a slice of the nodes.
 func (s *skipList) snapshot() []*Node {
-	s.mutex.RLock()
+	s.rlock()
 	defer s.mutex.RUnlock()
 
 	result := make([]*Node, s.length)
@@ -185,16 +337,181 @@ func (s *skipList) snapshot() []*Node {
 	return result
 }
 
-// getLength will return the length of skip list.
+// snapshotRange will create a snapshot of the nodes whose index is in [lo, hi).
+// It seeks to lo first, so only the nodes in range are visited.
+func (s *skipList) snapshotRange(lo, hi uint64) []*Node {
+	s.rlock()
+	defer s.mutex.RUnlock()
+
+	var result []*Node
+	for currentNode := s.findGreaterOrEqual(lo); currentNode != s.tail && currentNode.index < hi; currentNode = currentNode.nextNodes[0] {
+		result = append(result, &Node{
+			index:     currentNode.index,
+			value:     currentNode.value,
+			nextNodes: nil,
+		})
+	}
+
+	return result
+}
+
+// scan will return copies of at most limit nodes whose index is in [lo, hi] in ascending order.
+func (s *skipList) scan(lo, hi uint64, limit int) []*Node {
+	s.rlock()
+	defer s.mutex.RUnlock()
+
+	var result []*Node
+	for currentNode := s.findGreaterOrEqual(lo); currentNode != s.tail && currentNode.index <= hi && len(result) < limit; currentNode = currentNode.nextNodes[0] {
+		result = append(result, &Node{
+			index:     currentNode.index,
+			value:     currentNode.value,
+			nextNodes: nil,
+		})
+	}
+
+	return result
+}
+
+// scanReverse will return copies of at most limit nodes whose index is in [lo, hi] in descending order.
+// It seeks to the last node whose index <= hi, then follows the backward links.
+func (s *skipList) scanReverse(lo, hi uint64, limit int) []*Node {
+	s.rlock()
+	defer s.mutex.RUnlock()
+
+	var result []*Node
+	for currentNode := s.findLessOrEqual(hi); currentNode != s.head && currentNode.index >= lo && len(result) < limit; currentNode = currentNode.previousNode {
+		result = append(result, &Node{
+			index:     currentNode.index,
+			value:     currentNode.value,
+			nextNodes: nil,
+		})
+	}
+
+	return result
+}
+
+// ceiling will return a copy of the first node whose index is >= given index.
+// The node is copied under the read lock, so its value doesn't change with the later writes.
+// If can not find, return nil.
+func (s *skipList) ceiling(index uint64) *Node {
+	s.rlock()
+	defer s.mutex.RUnlock()
+
+	if currentNode := s.findGreaterOrEqual(index); currentNode != s.tail {
+		return currentNode.copy()
+	}
+
+	return nil
+}
+
+// floor will return a copy of the last node whose index is <= given index.
+// If can not find, return nil.
+func (s *skipList) floor(index uint64) *Node {
+	s.rlock()
+	defer s.mutex.RUnlock()
+
+	if currentNode := s.findLessOrEqual(index); currentNode != s.head {
+		return currentNode.copy()
+	}
+
+	return nil
+}
+
+// snapshotReverse will create a snapshot of the skip list in descending order.
+// It starts from the last node and follows the backward links.
+func (s *skipList) snapshotReverse() []*Node {
+	return s.subReverse(0, atomic.LoadInt32(&s.length))
+}
+
+// subReverse will skip the last startNumber nodes and return at most length nodes before them in descending order.
+// It seeks to the start position by spans, then follows the backward links.
+func (s *skipList) subReverse(startNumber int32, length int32) []*Node {
+	s.rlock()
+	defer s.mutex.RUnlock()
+
+	var result []*Node
+	currentNode := s.findByPosition(s.length - 1 - startNumber)
+	if currentNode == s.tail {
+		return nil
+	}
+
+	for ; currentNode != s.head && int32(len(result)) < length; currentNode = currentNode.previousNode {
+		result = append(result, &Node{
+			index:     currentNode.index,
+			value:     currentNode.value,
+			nextNodes: nil,
+		})
+	}
+
+	return result
+}
+
+// snapshotRangeReverse will create a snapshot of the nodes whose index is in [lo, hi) in descending order.
+// It seeks to the last node whose index < hi, then follows the backward links.
+func (s *skipList) snapshotRangeReverse(lo, hi uint64) []*Node {
+	s.rlock()
+	defer s.mutex.RUnlock()
+
+	var result []*Node
+	for currentNode := s.findLess(hi); currentNode != s.head && currentNode.index >= lo; currentNode = currentNode.previousNode {
+		result = append(result, &Node{
+			index:     currentNode.index,
+			value:     currentNode.value,
+			nextNodes: nil,
+		})
+	}
+
+	return result
+}
+
+// sub will skip the first startNumber nodes and return at most length nodes after them.
+// It seeks to startNumber by spans, so only the returned nodes are visited at level 0.
+func (s *skipList) sub(startNumber int32, length int32) []*Node {
+	s.rlock()
+	defer s.mutex.RUnlock()
+
+	return s.copyFrom(s.findByPosition(startNumber), length)
+}
+
+// at will return a copy of the node at the given position, the first node's position is 0.
+// The node is copied under the read lock, so its value doesn't change with the later writes.
+// If position is out of range, return nil.
+func (s *skipList) at(position int32) *Node {
+	s.rlock()
+	defer s.mutex.RUnlock()
+
+	if currentNode := s.findByPosition(position); currentNode != s.tail {
+		return currentNode.copy()
+	}
+
+	return nil
+}
+
+// rank will return the position of the node with given index, the first node's position is 0.
+// If can not find the given index, return -1.
+func (s *skipList) rank(index uint64) int32 {
+	s.rlock()
+	defer s.mutex.RUnlock()
+
+	return s.findRank(index)
+}
+
+// getLength will return the length of skip list. The expired nodes are removed first.
 func (s *skipList) getLength() int32 {
+	s.expire()
 	return atomic.LoadInt32(&s.length)
 }
 
-// randomLevel will generate and random level that level > 0 and level < skip list's level
-// This comes from redis's implementation.
-func (s *skipList) randomLevel() int {
+// randomLevel will generate and random level that level > 0 and level < maxLevel.
+// The level is promoted with given probability. If random is nil, use the shared source of math/rand.
+func randomLevel(maxLevel int, probability float64, random *rand.Rand) int {
+	next := rand.Float64
+	if random != nil {
+		next = random.Float64
+	}
+
 	level := 1
-	for rand.Float64() < PROBABILITY && level < s.level {
+	for next() < probability && level < maxLevel {
 		level++
 	}
 
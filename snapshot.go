@@ -0,0 +1,389 @@
+package ConcurrentSkipList
+
+import (
+	"errors"
+	"iter"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// versions is the sequence number of writes and the registry of active snapshots of a ConcurrentSkipList.
+// The sequence number only increases while there are active snapshots, so writes cost an atomic load otherwise.
+type versions struct {
+	sequence uint64
+	count    int32
+	mutex    sync.Mutex
+	// active is the count of active snapshots of each sequence number.
+	active map[uint64]int
+}
+
+// next will return the sequence number of a write, 0 means no snapshot is active and the write needn't be recorded.
+// It must be called with the write lock of the shard, so the sequence numbers in a shard are ascending.
+func (v *versions) next() uint64 {
+	if v == nil || atomic.LoadInt32(&v.count) == 0 {
+		return 0
+	}
+
+	return atomic.AddUint64(&v.sequence, 1)
+}
+
+// oldest will return the least sequence number of active snapshots and whether there is any.
+func (v *versions) oldest() (uint64, bool) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	var result uint64 = math.MaxUint64
+	for sequence := range v.active {
+		if sequence < result {
+			result = sequence
+		}
+	}
+
+	return result, len(v.active) > 0
+}
+
+// release will remove a snapshot of given sequence number from the registry.
+// The caller must hold the mutex.
+func (v *versions) release(sequence uint64) {
+	if v.active[sequence]--; v.active[sequence] == 0 {
+		delete(v.active, sequence)
+	}
+}
+
+// undoEntry is the value of an index before the write of sequence, nil value means the index didn't exist.
+type undoEntry struct {
+	sequence uint64
+	value    interface{}
+}
+
+// record will keep the value of the index before the current write for the snapshots.
+// The caller must hold the write lock.
+func (s *skipList) record(index uint64, value interface{}) {
+	if s.sequence == 0 {
+		return
+	}
+
+	if s.undo == nil {
+		s.undo = make(map[uint64][]undoEntry)
+	}
+
+	if _, ok := s.undo[index]; !ok {
+		i := sort.Search(len(s.undoIndexes), func(i int) bool {
+			return s.undoIndexes[i] >= index
+		})
+		s.undoIndexes = append(s.undoIndexes, 0)
+		copy(s.undoIndexes[i+1:], s.undoIndexes[i:])
+		s.undoIndexes[i] = index
+	}
+
+	s.undo[index] = append(s.undo[index], undoEntry{sequence: s.sequence, value: value})
+}
+
+// valueAt will return the value of the index seen by the snapshot of given sequence number.
+// The first write after the snapshot recorded the value, if there is no such write, the current value is seen.
+// The caller must hold the lock.
+func (s *skipList) valueAt(index uint64, sequence uint64) (interface{}, bool) {
+	entries := s.undo[index]
+	if i := sort.Search(len(entries), func(i int) bool {
+		return entries[i].sequence > sequence
+	}); i < len(entries) {
+		return entries[i].value, true
+	}
+
+	return nil, false
+}
+
+// changes iterates the indexes in [lo, hi] written after the snapshot of given sequence number
+// and their values seen by the snapshot in ascending order. It must be used with the lock held.
+type changes struct {
+	s        *skipList
+	position int
+	hi       uint64
+	sequence uint64
+}
+
+// changesAt will return the changes in [lo, hi] seen by the snapshot of given sequence number.
+// The first change is located by binary search, so a scan only visits the changes it merges.
+// The caller must hold the lock.
+func (s *skipList) changesAt(lo, hi uint64, sequence uint64) *changes {
+	position := sort.Search(len(s.undoIndexes), func(i int) bool {
+		return s.undoIndexes[i] >= lo
+	})
+
+	return &changes{s: s, position: position, hi: hi, sequence: sequence}
+}
+
+// next will return the next change as a node, nil value means the index didn't exist.
+// If there are no more changes, return nil.
+func (c *changes) next() *Node {
+	for ; c.position < len(c.s.undoIndexes) && c.s.undoIndexes[c.position] <= c.hi; c.position++ {
+		index := c.s.undoIndexes[c.position]
+		if value, ok := c.s.valueAt(index, c.sequence); ok {
+			c.position++
+			return &Node{index: index, value: value}
+		}
+	}
+
+	return nil
+}
+
+// searchAt will return a copy of the node with given index seen by the snapshot of given sequence number.
+// If can not find, return nil.
+func (s *skipList) searchAt(index uint64, sequence uint64) *Node {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if value, ok := s.valueAt(index, sequence); ok {
+		if value == nil {
+			return nil
+		}
+
+		return &Node{index: index, value: value}
+	}
+
+	if currentNode := s.findGreaterOrEqual(index); currentNode != s.tail && currentNode.index == index {
+		return &Node{index: index, value: currentNode.value}
+	}
+
+	return nil
+}
+
+// lengthAt will return the length seen by the snapshot of given sequence number.
+func (s *skipList) lengthAt(sequence uint64) int32 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	length := s.length
+	changes := s.changesAt(0, math.MaxUint64, sequence)
+	for change := changes.next(); change != nil; change = changes.next() {
+		if change.value != nil {
+			length++
+		}
+
+		if currentNode := s.findGreaterOrEqual(change.index); currentNode != s.tail && currentNode.index == change.index {
+			length--
+		}
+	}
+
+	return length
+}
+
+// scanAt will return copies of at most limit nodes whose index is in [lo, hi] seen by the snapshot
+// of given sequence number in ascending order. The current nodes are merged with the changes after the snapshot.
+func (s *skipList) scanAt(lo, hi uint64, limit int, sequence uint64) []*Node {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	changes := s.changesAt(lo, hi, sequence)
+	change := changes.next()
+	var result []*Node
+	currentNode := s.findGreaterOrEqual(lo)
+	for len(result) < limit {
+		current := currentNode != s.tail && currentNode.index <= hi
+		if change != nil && (!current || change.index <= currentNode.index) {
+			if current && change.index == currentNode.index {
+				currentNode = currentNode.nextNodes[0]
+			}
+
+			if change.value != nil {
+				result = append(result, change)
+			}
+
+			change = changes.next()
+			continue
+		}
+
+		if !current {
+			break
+		}
+
+		result = append(result, &Node{index: currentNode.index, value: currentNode.value})
+		currentNode = currentNode.nextNodes[0]
+	}
+
+	return result
+}
+
+// collect will drop the undo entries which no active snapshot needs.
+// A snapshot of sequence number n needs the entries whose sequence number > n.
+func (s *skipList) collect() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Read the snapshots with the lock, so no entry needed by a new snapshot is recorded before.
+	oldest, ok := s.versions.oldest()
+	if !ok {
+		s.undo = nil
+		s.undoIndexes = nil
+		return
+	}
+
+	for index, entries := range s.undo {
+		i := sort.Search(len(entries), func(i int) bool {
+			return entries[i].sequence > oldest
+		})
+
+		if i == len(entries) {
+			delete(s.undo, index)
+		} else if i > 0 {
+			s.undo[index] = append([]undoEntry(nil), entries[i:]...)
+		}
+	}
+
+	if len(s.undo) < len(s.undoIndexes) {
+		indexes := s.undoIndexes[:0]
+		for _, index := range s.undoIndexes {
+			if _, ok := s.undo[index]; ok {
+				indexes = append(indexes, index)
+			}
+		}
+
+		s.undoIndexes = indexes
+	}
+}
+
+// Snapshot is a read-only view of ConcurrentSkipList at a point in time, which is consistent across all shards.
+// Creating a snapshot copies nothing. While snapshots are active, each write keeps the value it overwrites
+// in an undo log of the shard, and a snapshot sees the current nodes with the writes after it undone.
+// So reading a snapshot costs more as the writes after it increase. Release the snapshot when it's no longer
+// used, then the undo logs which no snapshot needs are dropped.
+type Snapshot struct {
+	list     *ConcurrentSkipList
+	table    *routingTable
+	sequence uint64
+	released int32
+}
+
+// Snapshot will create a snapshot of the skip list.
+// Lock-free shards don't keep undo logs, so an error is returned for them.
+func (s *ConcurrentSkipList) Snapshot() (*Snapshot, error) {
+	if s.lockFree {
+		return nil, errors.New("lock-free skip list does not support snapshot")
+	}
+
+	// Register with the current sequence number first, so the writes after it are recorded and kept.
+	v := &s.versions
+	v.mutex.Lock()
+	atomic.AddInt32(&v.count, 1)
+	registered := atomic.LoadUint64(&v.sequence)
+	if v.active == nil {
+		v.active = make(map[uint64]int)
+	}
+
+	v.active[registered]++
+	v.mutex.Unlock()
+
+	// A writer may have read no active snapshot before registering, so wait for the writers holding
+	// the locks of shards. If the routing table is replaced meanwhile, the new shards may contain
+	// the writes before the sequence number, so wait again.
+	var table *routingTable
+	var sequence uint64
+	for {
+		table = s.loadTable()
+		for _, sl := range table.skipLists {
+			// The nodes expired before the snapshot are invisible to it.
+			sl.(*skipList).expire()
+			sl.(*skipList).mutex.Lock()
+			sl.(*skipList).mutex.Unlock()
+		}
+
+		sequence = atomic.LoadUint64(&v.sequence)
+		if s.loadTable() == table {
+			break
+		}
+	}
+
+	v.mutex.Lock()
+	v.release(registered)
+	v.active[sequence]++
+	v.mutex.Unlock()
+
+	return &Snapshot{
+		list:     s,
+		table:    table,
+		sequence: sequence,
+	}, nil
+}
+
+// Release will release the snapshot and drop the undo logs which no active snapshot needs.
+// The snapshot must not be used after releasing. Releasing more than once does nothing.
+func (sn *Snapshot) Release() {
+	if !atomic.CompareAndSwapInt32(&sn.released, 0, 1) {
+		return
+	}
+
+	v := &sn.list.versions
+	v.mutex.Lock()
+	v.release(sn.sequence)
+	atomic.AddInt32(&v.count, -1)
+	v.mutex.Unlock()
+
+	// The retired shards are only referenced by the old snapshots, so their undo logs are dropped with them.
+	for _, sl := range sn.list.loadTable().skipLists {
+		sl.(*skipList).collect()
+	}
+}
+
+// Length will return the length of the snapshot.
+func (sn *Snapshot) Length() int32 {
+	var length int32
+	for _, sl := range sn.table.skipLists {
+		length += sl.(*skipList).lengthAt(sn.sequence)
+	}
+
+	return length
+}
+
+// Search will return the node with given index in the snapshot.
+// If the node exists, return the node and true, otherwise return nil and false.
+func (sn *Snapshot) Search(index uint64) (*Node, bool) {
+	sl := sn.table.skipLists[sn.table.partitioner.Shard(index)].(*skipList)
+	result := sl.searchAt(index, sn.sequence)
+	return result, result != nil
+}
+
+// ForEach will iterate each node of the snapshot in ascending order and do the function f().
+// If f() return false, stop iterating and return. The nodes are copied batch by batch and no lock is held
+// while calling f(). If the shards are not ordered, the nodes are only ordered in each shard.
+func (sn *Snapshot) ForEach(f func(node *Node) bool) {
+	sn.stream(0, math.MaxUint64, f)
+}
+
+// Range will iterate the nodes of the snapshot whose index is in [lo, hi) in ascending order and do the function f().
+// If f() return false, stop iterating and return.
+func (sn *Snapshot) Range(lo, hi uint64, f func(node *Node) bool) {
+	// Ignore empty range.
+	if lo >= hi {
+		return
+	}
+
+	sn.stream(lo, hi-1, f)
+}
+
+// All will return an iterator of the index and value of each node of the snapshot in ascending order.
+func (sn *Snapshot) All() iter.Seq2[uint64, interface{}] {
+	return func(yield func(uint64, interface{}) bool) {
+		sn.stream(0, math.MaxUint64, func(node *Node) bool {
+			return yield(node.index, node.value)
+		})
+	}
+}
+
+// stream will call f() with the nodes of the snapshot whose index is in [lo, hi] shard by shard.
+func (sn *Snapshot) stream(lo, hi uint64, f func(node *Node) bool) {
+	first, last := 0, len(sn.table.skipLists)-1
+	if sn.table.partitioner.Ordered() {
+		first, last = sn.table.partitioner.Shard(lo), sn.table.partitioner.Shard(hi)
+	}
+
+	for i := first; i <= last; i++ {
+		sl := sn.table.skipLists[i].(*skipList)
+		if !streamBatches(lo, hi, true, func(lo, hi uint64) []*Node {
+			return sl.scanAt(lo, hi, streamBatchSize, sn.sequence)
+		}, f) {
+			return
+		}
+	}
+}
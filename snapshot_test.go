@@ -0,0 +1,241 @@
+package ConcurrentSkipList
+
+import (
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentSkipList_Snapshot(t *testing.T) {
+	t.Run("test isolation", func(t *testing.T) {
+		skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, Shards: 4})
+		for i := uint64(0); i < 10; i++ {
+			skipList.Insert(i, i)
+		}
+
+		snapshot, err := skipList.Snapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer snapshot.Release()
+		skipList.Insert(1, 100)
+		skipList.Insert(3, 300)
+		skipList.Insert(3, 301)
+		skipList.Delete(5)
+		skipList.Insert(20, 20)
+		skipList.PopFirst()
+		skipList.InsertBatch([]Entry{{Index: 7, Value: 700}, {Index: 30, Value: 30}})
+
+		if snapshot.Length() != 10 {
+			t.Errorf("snapshot's length = %d, want 10", snapshot.Length())
+		}
+
+		for i := uint64(0); i < 10; i++ {
+			if node, ok := snapshot.Search(i); !ok || node.Value() != i {
+				t.Errorf("Search(%d) = %v, want %d", i, node, i)
+			}
+		}
+
+		if _, ok := snapshot.Search(20); ok {
+			t.Errorf("Search() should not find the index inserted after the snapshot")
+		}
+
+		var indexes []uint64
+		snapshot.Range(2, 8, func(node *Node) bool {
+			if node.Value() != node.Index() {
+				t.Errorf("value of %d = %v", node.Index(), node.Value())
+			}
+
+			indexes = append(indexes, node.Index())
+			return true
+		})
+		if len(indexes) != 6 || indexes[0] != 2 || indexes[5] != 7 {
+			t.Errorf("Range() = %v, want [2, 8)", indexes)
+		}
+
+		count := 0
+		for index, value := range snapshot.All() {
+			if index != uint64(count) || value != index {
+				t.Errorf("All() yields %d:%v at %d", index, value, count)
+			}
+
+			count++
+		}
+
+		if count != 10 {
+			t.Errorf("All() yields %d nodes, want 10", count)
+		}
+
+		if node, ok := skipList.Search(3); !ok || node.Value() != 301 || skipList.Length() != 10 {
+			t.Errorf("the skip list should see the new writes")
+		}
+	})
+
+	t.Run("test consistent across shards", func(t *testing.T) {
+		skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, Shards: 4})
+		indexes := newShardIndexes(4)
+		accounts := []uint64{1, indexes[0] + 1, indexes[1] + 1, indexes[2] + 1}
+		for _, index := range accounts {
+			skipList.Insert(index, 100)
+		}
+
+		wg := &sync.WaitGroup{}
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 200; j++ {
+					from, to := accounts[(i+j)%4], accounts[(i+j+1)%4]
+					_ = skipList.Update(func(tx *Txn) error {
+						a, _ := tx.Search(from)
+						b, _ := tx.Search(to)
+						if a == nil || b == nil {
+							return nil
+						}
+
+						tx.Insert(from, a.Value().(int)-1)
+						tx.Insert(to, b.Value().(int)+1)
+						return nil
+					})
+				}
+			}(i)
+		}
+
+		for i := 0; i < 100; i++ {
+			snapshot, err := skipList.Snapshot()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sum := 0
+			snapshot.ForEach(func(node *Node) bool {
+				sum += node.Value().(int)
+				return true
+			})
+			snapshot.Release()
+
+			if sum != 400 {
+				t.Fatalf("sum of snapshot = %d, want 400", sum)
+			}
+		}
+
+		wg.Wait()
+	})
+
+	t.Run("test split", func(t *testing.T) {
+		skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, SplitLength: 64})
+		for i := uint64(0); i < 64; i++ {
+			skipList.Insert(i, i)
+		}
+
+		snapshot, _ := skipList.Snapshot()
+		defer snapshot.Release()
+		for i := uint64(64); i < 200; i++ {
+			skipList.Insert(i, i)
+		}
+
+		skipList.Delete(0)
+		skipList.Balance()
+		if snapshot.Length() != 64 {
+			t.Errorf("snapshot's length = %d, want 64", snapshot.Length())
+		}
+
+		if _, ok := snapshot.Search(0); !ok {
+			t.Errorf("Search() should find the index deleted after the snapshot")
+		}
+	})
+
+	t.Run("test release", func(t *testing.T) {
+		list, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, Shards: 1})
+		list.Insert(1, 1)
+
+		first, _ := list.Snapshot()
+		list.Insert(1, 2)
+		second, _ := list.Snapshot()
+		list.Insert(1, 3)
+
+		sl := list.loadTable().skipLists[0].(*skipList)
+		if len(sl.undo[1]) != 2 {
+			t.Errorf("undo log's length = %d, want 2", len(sl.undo[1]))
+		}
+
+		first.Release()
+		first.Release()
+		if len(sl.undo[1]) != 1 {
+			t.Errorf("undo log's length = %d, want 1", len(sl.undo[1]))
+		}
+
+		if node, _ := second.Search(1); node.Value() != 2 {
+			t.Errorf("Search() = %v, want 2", node.Value())
+		}
+
+		second.Release()
+		if sl.undo != nil {
+			t.Errorf("undo log should be dropped")
+		}
+
+		list.Insert(1, 4)
+		if sl.undo != nil {
+			t.Errorf("writes should not be recorded without snapshots")
+		}
+	})
+
+	t.Run("test many changes", func(t *testing.T) {
+		list, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, Shards: 1})
+		for i := uint64(0); i < 1000; i += 2 {
+			list.Insert(i, i)
+		}
+
+		first, _ := list.Snapshot()
+		for i := uint64(999); i < 1000; i-- {
+			list.Insert(i, i+1)
+		}
+
+		second, _ := list.Snapshot()
+		for i := uint64(0); i < 1000; i += 4 {
+			list.Delete(i)
+		}
+
+		sl := list.loadTable().skipLists[0].(*skipList)
+		if len(sl.undoIndexes) != 1000 || !sort.SliceIsSorted(sl.undoIndexes, func(i, j int) bool {
+			return sl.undoIndexes[i] < sl.undoIndexes[j]
+		}) {
+			t.Fatalf("undo indexes should be the 1000 changed indexes in ascending order")
+		}
+
+		var want uint64
+		for index, value := range first.All() {
+			if index != want || value != want {
+				t.Fatalf("All() yields %d:%v, want %d", index, value, want)
+			}
+
+			want += 2
+		}
+
+		if want != 1000 || first.Length() != 500 {
+			t.Errorf("first snapshot has %d nodes, want 500", first.Length())
+		}
+
+		if second.Length() != 1000 {
+			t.Errorf("second snapshot's length = %d, want 1000", second.Length())
+		}
+
+		first.Release()
+		if len(sl.undoIndexes) != 250 {
+			t.Errorf("undo indexes = %d after release, want 250", len(sl.undoIndexes))
+		}
+
+		second.Release()
+		if sl.undoIndexes != nil {
+			t.Errorf("undo indexes should be dropped")
+		}
+	})
+
+	t.Run("test lock-free", func(t *testing.T) {
+		skipList, _ := NewLockFreeConcurrentSkipList(12)
+		if _, err := skipList.Snapshot(); err == nil {
+			t.Errorf("Snapshot() should return an error for lock-free skip list")
+		}
+	})
+}
@@ -0,0 +1,246 @@
+package ConcurrentSkipList
+
+import (
+	"container/heap"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// expiration is the deadline of an index in the expiration queue of a shard.
+type expiration struct {
+	deadline int64
+	index    uint64
+}
+
+// expirationQueue is a min-heap of expirations ordered by deadline.
+// When the TTL of an index is dropped, its expiration is kept in the queue and skipped when it's popped.
+type expirationQueue []expiration
+
+// Len implements heap.Interface.
+func (q expirationQueue) Len() int {
+	return len(q)
+}
+
+// Less implements heap.Interface.
+func (q expirationQueue) Less(i, j int) bool {
+	return q[i].deadline < q[j].deadline
+}
+
+// Swap implements heap.Interface.
+func (q expirationQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+// Push implements heap.Interface.
+func (q *expirationQueue) Push(x interface{}) {
+	*q = append(*q, x.(expiration))
+}
+
+// Pop implements heap.Interface.
+func (q *expirationQueue) Pop() interface{} {
+	old := *q
+	result := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return result
+}
+
+// setDeadline will set the deadline of the index, the index must exist.
+// The caller must hold the write lock or the skip list is not published yet.
+func (s *skipList) setDeadline(index uint64, deadline int64) {
+	if s.deadlines == nil {
+		s.deadlines = make(map[uint64]int64)
+	}
+
+	s.deadlines[index] = deadline
+	heap.Push(&s.expirations, expiration{deadline: deadline, index: index})
+	atomic.StoreInt64(&s.nextDeadline, s.expirations[0].deadline)
+}
+
+// dropDeadline will drop the deadline of the index if any.
+// The caller must hold the write lock.
+func (s *skipList) dropDeadline(index uint64) {
+	if _, ok := s.deadlines[index]; !ok {
+		return
+	}
+
+	delete(s.deadlines, index)
+	if len(s.deadlines) == 0 {
+		s.deadlines, s.expirations = nil, nil
+		atomic.StoreInt64(&s.nextDeadline, 0)
+	}
+}
+
+// expiredLocked will return whether the index has expired but not been removed yet.
+// The caller must hold the lock.
+func (s *skipList) expiredLocked(index uint64) bool {
+	deadline, ok := s.deadlines[index]
+	return ok && deadline <= time.Now().UnixNano()
+}
+
+// expire will remove the expired nodes if any and call onExpire with them after releasing the lock.
+// It's called before reading and writing the skip list, so the expired nodes are never seen.
+func (s *skipList) expire() {
+	deadline := atomic.LoadInt64(&s.nextDeadline)
+	if deadline == 0 || deadline > time.Now().UnixNano() {
+		return
+	}
+
+	s.mutex.Lock()
+	if s.retired {
+		s.mutex.Unlock()
+		return
+	}
+
+	s.sequence = s.versions.next()
+	var expired []*Node
+	now := time.Now().UnixNano()
+	for len(s.expirations) > 0 && s.expirations[0].deadline <= now {
+		e := heap.Pop(&s.expirations).(expiration)
+		if deadline, ok := s.deadlines[e.index]; !ok || deadline != e.deadline {
+			continue
+		}
+
+		// deleteLocked drops the deadline.
+		if value, ok := s.deleteLocked(e.index); ok {
+			expired = append(expired, &Node{index: e.index, value: value})
+		}
+	}
+
+	if len(s.expirations) > 0 {
+		atomic.StoreInt64(&s.nextDeadline, s.expirations[0].deadline)
+	} else {
+		atomic.StoreInt64(&s.nextDeadline, 0)
+	}
+
+	s.unlock()
+
+	if s.onExpire != nil {
+		for _, node := range expired {
+			s.onExpire(node.index, node.value)
+		}
+	}
+}
+
+// rlock will acquire the read lock after removing the expired nodes.
+func (s *skipList) rlock() {
+	s.expire()
+	s.mutex.RLock()
+}
+
+// insertWithDeadline will insert a value into skip list which expires at given deadline.
+// If the skip list is retired, forward to the shard which the index belongs to now.
+func (s *skipList) insertWithDeadline(index uint64, value interface{}, deadline int64) {
+	if sl := s.lockIndex(index); sl != nil {
+		sl.(*skipList).insertWithDeadline(index, value, deadline)
+		return
+	}
+	defer s.unlock()
+
+	// insertLocked drops the old deadline.
+	s.insertLocked(index, value)
+	s.setDeadline(index, deadline)
+}
+
+// copyDeadlines will copy the deadlines of old shard to the shards which the indexes belong to.
+// The caller must hold the lock of old shard, and the new shards must not be published yet.
+func copyDeadlines(old *skipList, shardFor func(index uint64) shard) {
+	for index, deadline := range old.deadlines {
+		shardFor(index).(*skipList).setDeadline(index, deadline)
+	}
+}
+
+// InsertWithTTL will insert a value into skip list which expires after ttl. If skip has these this index,
+// overwrite the value and the TTL, otherwise add it. Inserting the index again without TTL drops the TTL.
+// Once expired, the node is invisible to all operations immediately. It's removed lazily when its shard
+// is accessed or by a janitor running in background, then Options.OnExpire is called with it.
+// Lock-free shards can't be locked to remove the expired nodes, and the checkpoints of durable skip lists
+// don't keep the deadlines, so an error is returned for them. A nil value is ignored like Insert.
+func (s *ConcurrentSkipList) InsertWithTTL(index uint64, value interface{}, ttl time.Duration) error {
+	if s.lockFree {
+		return errors.New("lock-free skip list does not support TTL")
+	}
+
+	if s.wal != nil {
+		return errors.New("durable skip list does not support TTL")
+	}
+
+	if ttl <= 0 {
+		return errors.New("invalid ttl, ttl must be positive")
+	}
+
+	// Ignore nil value.
+	if value == nil {
+		return nil
+	}
+
+	table := s.loadTable()
+	if o, ok := table.partitioner.(observer); ok {
+		o.observe(index)
+	}
+
+	deadline := time.Now().Add(ttl).UnixNano()
+	sl := table.skipLists[table.partitioner.Shard(index)]
+	sl.(*skipList).insertWithDeadline(index, value, deadline)
+	s.checkSplit(sl)
+	s.checkCapacity()
+	s.expireAsync(deadline)
+	return nil
+}
+
+// nextDeadline will return the earliest deadline of all shards, 0 means no index will expire.
+func (s *ConcurrentSkipList) nextDeadline() int64 {
+	var result int64
+	for _, sl := range s.loadTable().skipLists {
+		if deadline := atomic.LoadInt64(&sl.(*skipList).nextDeadline); deadline != 0 && (result == 0 || deadline < result) {
+			result = deadline
+		}
+	}
+
+	return result
+}
+
+// expireAsync will start the janitor in background if it's not running,
+// or wake it if given deadline is earlier than the one it's waiting for.
+func (s *ConcurrentSkipList) expireAsync(deadline int64) {
+	if atomic.CompareAndSwapInt32(&s.expiring, 0, 1) {
+		go s.janitor()
+		return
+	}
+
+	if deadline < atomic.LoadInt64(&s.janitorDeadline) {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// janitor will wait for the earliest deadline and remove the expired nodes of all shards,
+// until no index will expire.
+func (s *ConcurrentSkipList) janitor() {
+	for {
+		deadline := s.nextDeadline()
+		if deadline == 0 {
+			atomic.StoreInt32(&s.expiring, 0)
+			// A deadline may be set after checking, continue if no other janitor is started for it.
+			if s.nextDeadline() == 0 || !atomic.CompareAndSwapInt32(&s.expiring, 0, 1) {
+				return
+			}
+
+			continue
+		}
+
+		atomic.StoreInt64(&s.janitorDeadline, deadline)
+		timer := time.NewTimer(time.Duration(deadline - time.Now().UnixNano()))
+		select {
+		case <-timer.C:
+		case <-s.wake:
+			timer.Stop()
+		}
+
+		for _, sl := range s.loadTable().skipLists {
+			sl.(*skipList).expire()
+		}
+	}
+}
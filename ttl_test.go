@@ -0,0 +1,130 @@
+package ConcurrentSkipList
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentSkipList_InsertWithTTL(t *testing.T) {
+	t.Run("test expire", func(t *testing.T) {
+		var mutex sync.Mutex
+		expired := make(map[uint64]interface{})
+		skipList, _ := NewConcurrentSkipListWithOptions(Options{
+			MaxLevel: 12,
+			Shards:   4,
+			OnExpire: func(index uint64, value interface{}) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				expired[index] = value
+			},
+		})
+
+		skipList.Insert(1, 1)
+		for _, index := range []uint64{2, 3, shardIndexes[0]} {
+			if err := skipList.InsertWithTTL(index, index, 50*time.Millisecond); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Inserting again without TTL drops the TTL.
+		skipList.Insert(3, 3)
+		if _, ok := skipList.Search(2); !ok || skipList.Length() != 4 {
+			t.Errorf("the nodes should not expire before TTL")
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		if _, ok := skipList.Search(2); ok {
+			t.Errorf("Search() should not find the expired node")
+		}
+
+		if nodes := skipList.Sub(0, 10); len(nodes) != 2 || nodes[0].Index() != 1 || nodes[1].Index() != 3 {
+			t.Errorf("Sub() = %v, want [1, 3]", nodes)
+		}
+
+		count := 0
+		skipList.ForEach(func(node *Node) bool {
+			count++
+			return true
+		})
+		if count != 2 || skipList.Length() != 2 {
+			t.Errorf("ForEach() visits %d nodes and length is %d, want 2", count, skipList.Length())
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		if len(expired) != 2 || expired[2] != uint64(2) || expired[shardIndexes[0]] != shardIndexes[0] {
+			t.Errorf("OnExpire() is called with %v", expired)
+		}
+	})
+
+	t.Run("test janitor", func(t *testing.T) {
+		done := make(chan uint64, 10)
+		skipList, _ := NewConcurrentSkipListWithOptions(Options{
+			MaxLevel: 12,
+			OnExpire: func(index uint64, value interface{}) {
+				done <- index
+			},
+		})
+
+		_ = skipList.InsertWithTTL(1, 1, time.Hour)
+		// The earlier deadline wakes the janitor waiting for the later one.
+		_ = skipList.InsertWithTTL(2, 2, 20*time.Millisecond)
+		select {
+		case index := <-done:
+			if index != 2 {
+				t.Errorf("expired index = %d, want 2", index)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("the janitor should remove the expired node without access")
+		}
+
+		skipList.Delete(1)
+		time.Sleep(10 * time.Millisecond)
+		if skipList.nextDeadline() != 0 {
+			t.Errorf("no index should expire")
+		}
+	})
+
+	t.Run("test split", func(t *testing.T) {
+		skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, SplitLength: 64})
+		for i := uint64(0); i < 100; i++ {
+			_ = skipList.InsertWithTTL(i, i, 50*time.Millisecond)
+		}
+
+		skipList.Balance()
+		if len(skipList.loadTable().skipLists) < 2 {
+			t.Fatalf("the shard should be split")
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		if skipList.Length() != 0 {
+			t.Errorf("the nodes should expire after splitting, length is %d", skipList.Length())
+		}
+	})
+
+	t.Run("test update", func(t *testing.T) {
+		skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12})
+		_ = skipList.InsertWithTTL(1, 1, 20*time.Millisecond)
+		_ = skipList.Update(func(tx *Txn) error {
+			time.Sleep(40 * time.Millisecond)
+			if _, ok := tx.Search(1); ok {
+				t.Errorf("Search() should not find the expired node")
+			}
+
+			return nil
+		})
+	})
+
+	t.Run("test invalid", func(t *testing.T) {
+		skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12})
+		if err := skipList.InsertWithTTL(1, 1, 0); err == nil {
+			t.Errorf("InsertWithTTL() should return an error for non-positive ttl")
+		}
+
+		lockFreeSkipList, _ := NewLockFreeConcurrentSkipList(12)
+		if err := lockFreeSkipList.InsertWithTTL(1, 1, time.Second); err == nil {
+			t.Errorf("InsertWithTTL() should return an error for lock-free skip list")
+		}
+	})
+}
@@ -0,0 +1,228 @@
+package ConcurrentSkipList
+
+import (
+	"errors"
+	"sort"
+)
+
+// Txn is a transaction of ConcurrentSkipList.Update. The reads and writes of a transaction are isolated
+// from other operations and the writes are applied all-or-nothing.
+// The shards are locked when they are accessed first and kept locked until the transaction ends.
+// The writes are staged and the reads see the staged writes. A Txn must only be used in the function of Update.
+type Txn struct {
+	list  *ConcurrentSkipList
+	table *routingTable
+	// locked is the positions of the locked shards in table, in ascending order.
+	locked []int
+	// writes is the staged writes, nil value means deleted.
+	writes map[uint64]interface{}
+	// accessed is the indexes accessed by the transaction, the next attempt locks their shards first.
+	accessed []uint64
+	// aborted means a shard can't be locked in order, the transaction must restart.
+	aborted   bool
+	committed bool
+}
+
+// Update will run f in a transaction and apply the writes of it atomically if f return nil.
+// If f return an error, the writes are discarded and the error is returned.
+//
+// To avoid deadlock, the shards are always locked in ascending order. If f accesses a shard before
+// an already locked one, the transaction restarts and locks all accessed shards in order first,
+// so f may be called more than once and it must not have side effects out of the transaction.
+// The reads of an aborted attempt may be wrong, but its result is discarded.
+// While f is running, the accessed shards are locked, so f must not access the skip list except by tx.
+// Lock-free shards can't be locked, so Update return an error for them.
+func (s *ConcurrentSkipList) Update(f func(tx *Txn) error) error {
+	if s.lockFree {
+		return errors.New("lock-free skip list does not support transaction")
+	}
+
+	var accessed []uint64
+	for {
+		tx := &Txn{
+			list:     s,
+			table:    s.loadTable(),
+			writes:   make(map[uint64]interface{}),
+			accessed: accessed,
+		}
+
+		err := tx.run(f)
+		accessed = tx.accessed
+		if !tx.aborted {
+			return err
+		}
+	}
+}
+
+// run will lock the shards accessed by the previous attempts, call f and commit if f return nil.
+func (tx *Txn) run(f func(tx *Txn) error) error {
+	defer tx.unlock()
+
+	positions := make([]int, 0, len(tx.accessed))
+	for _, index := range tx.accessed {
+		positions = append(positions, tx.table.partitioner.Shard(index))
+	}
+
+	sort.Ints(positions)
+	for _, position := range positions {
+		if len(tx.locked) > 0 && tx.locked[len(tx.locked)-1] == position {
+			continue
+		}
+
+		if !tx.table.skipLists[position].(*skipList).lock() {
+			tx.aborted = true
+			return nil
+		}
+
+		tx.locked = append(tx.locked, position)
+	}
+
+	err := f(tx)
+	if tx.aborted || err != nil {
+		return err
+	}
+
+	tx.commit()
+	tx.committed = true
+	return nil
+}
+
+// Search will return the value of the index, including the staged writes.
+// If the index doesn't exist, return nil and false.
+func (tx *Txn) Search(index uint64) (*Node, bool) {
+	if value, ok := tx.writes[index]; ok {
+		if value == nil {
+			return nil, false
+		}
+
+		return &Node{index: index, value: value}, true
+	}
+
+	sl := tx.lockShard(index)
+	if sl == nil {
+		return nil, false
+	}
+
+	// The transaction doesn't remove the expired nodes, so skip them.
+	if sl.expiredLocked(index) {
+		return nil, false
+	}
+
+	if currentNode := sl.findGreaterOrEqual(index); currentNode != sl.tail && currentNode.index == index {
+		return &Node{index: currentNode.index, value: currentNode.value}, true
+	}
+
+	return nil, false
+}
+
+// Insert will stage the insertion of the index. A nil value is ignored like ConcurrentSkipList.Insert.
+func (tx *Txn) Insert(index uint64, value interface{}) {
+	// Ignore nil value.
+	if value == nil {
+		return
+	}
+
+	if tx.lockShard(index) != nil {
+		tx.writes[index] = value
+	}
+}
+
+// Delete will stage the deletion of the index.
+func (tx *Txn) Delete(index uint64) {
+	if tx.lockShard(index) != nil {
+		tx.writes[index] = nil
+	}
+}
+
+// lockShard will return the locked shard of the index. If the shard isn't locked, lock it if it's after
+// all locked shards, otherwise abort the transaction. If the shard is retired, abort the transaction too.
+// Return nil if the transaction is aborted.
+func (tx *Txn) lockShard(index uint64) *skipList {
+	if tx.aborted {
+		return nil
+	}
+
+	position := tx.table.partitioner.Shard(index)
+	sl := tx.table.skipLists[position].(*skipList)
+	i := sort.SearchInts(tx.locked, position)
+	if i < len(tx.locked) && tx.locked[i] == position {
+		return sl
+	}
+
+	tx.accessed = append(tx.accessed, index)
+	if i < len(tx.locked) || !sl.lock() {
+		tx.aborted = true
+		return nil
+	}
+
+	tx.locked = append(tx.locked, position)
+	return sl
+}
+
+// commit will apply the staged writes to the locked shards.
+// All writes share one sequence number, so a snapshot sees all or none of them.
+func (tx *Txn) commit() {
+	sequence := tx.list.versions.next()
+	for _, position := range tx.locked {
+		tx.table.skipLists[position].(*skipList).sequence = sequence
+	}
+
+	// The writes of the transaction are logged as one record, so they are replayed all or nothing.
+	var records [][]byte
+	if tx.list.wal != nil {
+		for _, position := range tx.locked {
+			tx.table.skipLists[position].(*skipList).records = &records
+		}
+	}
+
+	o, observed := tx.table.partitioner.(observer)
+	for index, value := range tx.writes {
+		sl := tx.table.skipLists[tx.table.partitioner.Shard(index)].(*skipList)
+		if value == nil {
+			sl.deleteLocked(index)
+			continue
+		}
+
+		if observed {
+			o.observe(index)
+		}
+
+		sl.insertLocked(index, value)
+	}
+
+	if tx.list.wal != nil {
+		for _, position := range tx.locked {
+			tx.table.skipLists[position].(*skipList).records = nil
+		}
+
+		tx.list.wal.appendBatch(records)
+	}
+}
+
+// unlock will release the locked shards, publish the events of the writes and check whether the shards
+// need to be split or merged. All shards are released before publishing, so a blocked watch holds no lock.
+func (tx *Txn) unlock() {
+	var emitted []*skipList
+	for _, position := range tx.locked {
+		sl := tx.table.skipLists[position].(*skipList)
+		if sl.release() {
+			emitted = append(emitted, sl)
+		}
+	}
+
+	for _, sl := range emitted {
+		sl.publish()
+	}
+
+	if !tx.committed || len(tx.writes) == 0 {
+		return
+	}
+
+	for _, position := range tx.locked {
+		sl := tx.table.skipLists[position]
+		tx.list.checkSplit(sl)
+		tx.list.checkMerge(sl)
+	}
+
+	tx.list.checkCapacity()
+}
@@ -0,0 +1,158 @@
+package ConcurrentSkipList
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentSkipList_Update(t *testing.T) {
+	skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, Shards: 4})
+	indexes := newShardIndexes(4)
+	accounts := []uint64{1, indexes[0] + 1, indexes[1] + 1, indexes[2] + 1}
+	for _, index := range accounts {
+		skipList.Insert(index, 100)
+	}
+
+	t.Run("test read staged writes", func(t *testing.T) {
+		err := skipList.Update(func(tx *Txn) error {
+			tx.Insert(2, 2)
+			tx.Delete(accounts[0])
+			if node, ok := tx.Search(2); !ok || node.Value() != 2 {
+				t.Errorf("Search() = %v, want 2", node)
+			}
+
+			if _, ok := tx.Search(accounts[0]); ok {
+				t.Errorf("Search() should not find the deleted index")
+			}
+
+			return errors.New("rollback")
+		})
+		if err == nil || err.Error() != "rollback" {
+			t.Errorf("Update() error = %v, want rollback", err)
+		}
+
+		if _, ok := skipList.Search(2); ok || skipList.Length() != int32(len(accounts)) {
+			t.Errorf("the writes should be discarded")
+		}
+	})
+
+	t.Run("test restart", func(t *testing.T) {
+		// Access the last shard first, then the first shard, so the transaction restarts.
+		calls := 0
+		err := skipList.Update(func(tx *Txn) error {
+			calls++
+			tx.Insert(accounts[3]+1, 1)
+			tx.Insert(2, 2)
+			return nil
+		})
+		if err != nil || calls != 2 {
+			t.Errorf("Update() error = %v, calls = %d, want 2 calls", err, calls)
+		}
+
+		if skipList.Length() != int32(len(accounts)+2) {
+			t.Errorf("skip list's length is not correct, got %d", skipList.Length())
+		}
+
+		skipList.DeleteBatch([]uint64{accounts[3] + 1, 2})
+	})
+
+	t.Run("test transfer parallel", func(t *testing.T) {
+		transfer := func(from, to uint64) error {
+			return skipList.Update(func(tx *Txn) error {
+				a, _ := tx.Search(from)
+				b, _ := tx.Search(to)
+				if a == nil || b == nil {
+					return errors.New("account not found")
+				}
+
+				if a.Value().(int) == 0 {
+					return nil
+				}
+
+				tx.Insert(from, a.Value().(int)-1)
+				tx.Insert(to, b.Value().(int)+1)
+				return nil
+			})
+		}
+
+		sum := func() (int, error) {
+			total := 0
+			err := skipList.Update(func(tx *Txn) error {
+				total = 0
+				for _, index := range accounts {
+					node, ok := tx.Search(index)
+					if !ok {
+						return errors.New("account not found")
+					}
+
+					total += node.Value().(int)
+				}
+
+				return nil
+			})
+
+			return total, err
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					from, to := accounts[(i+j)%4], accounts[(i+j+1+j%3)%4]
+					if err := transfer(from, to); err != nil {
+						t.Errorf("transfer() error %v", err)
+						return
+					}
+				}
+			}(i)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if total, err := sum(); err != nil || total != 400 {
+					t.Errorf("sum() = %v, %v, want 400", total, err)
+					return
+				}
+			}
+		}()
+
+		wg.Wait()
+	})
+
+	t.Run("test split while updating", func(t *testing.T) {
+		partitioner, _ := NewRangePartitioner()
+		splitSkipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, Partitioner: partitioner, SplitLength: 100})
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := i; j < 2000; j += 4 {
+					// The shards are retired by splitting, the transaction restarts with the new shards.
+					splitSkipList.Update(func(tx *Txn) error {
+						tx.Insert(uint64(j), j)
+						tx.Insert(uint64(j+10000), j)
+						return nil
+					})
+				}
+			}(i)
+		}
+
+		wg.Wait()
+		if length := splitSkipList.Length(); length != 4000 {
+			t.Errorf("skip list's length is not correct, got %d", length)
+		}
+	})
+
+	t.Run("test lock-free", func(t *testing.T) {
+		lockFreeSkipList, _ := NewLockFreeConcurrentSkipList(12)
+		if err := lockFreeSkipList.Update(func(tx *Txn) error { return nil }); err == nil {
+			t.Errorf("Update() should return error for lock-free skip list")
+		}
+	})
+}
@@ -0,0 +1,674 @@
+package ConcurrentSkipList
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SyncPolicy decides when the write-ahead log is flushed to the disk by fsync.
+// The records are written to the file before the writes are applied, so they survive the crash of
+// the process anyway, the policy decides how many writes may be lost if the operating system crashes.
+type SyncPolicy int
+
+const (
+	// SyncAlways syncs the log after each write. No write is lost but each write waits for the disk.
+	SyncAlways SyncPolicy = iota
+	// SyncBatch syncs the log after every WALOptions.BatchSize writes.
+	SyncBatch
+	// SyncInterval syncs the log every WALOptions.Interval in background.
+	SyncInterval
+)
+
+// WALOptions is the configuration of the write-ahead log of Open.
+type WALOptions struct {
+	// Dir is the directory of the log and the checkpoints, it's created if not existed.
+	Dir string
+
+	// Sync is the policy to sync the log, the default policy is SyncAlways.
+	Sync SyncPolicy
+
+	// BatchSize is the count of writes between syncs for SyncBatch, it must be greater than 0.
+	BatchSize int
+
+	// Interval is the time between syncs for SyncInterval, it must be greater than 0.
+	Interval time.Duration
+}
+
+// The types of log records.
+const (
+	walPut byte = iota + 1
+	walDelete
+	walBatch
+)
+
+// walHeaderSize is the size of the header of a log record: the length and the CRC-32C of payload.
+const walHeaderSize = 8
+
+// wal is the write-ahead log of a durable skip list. The log of each generation is a file of records:
+//
+//	length of payload (uint32) | CRC-32C of payload (uint32) | payload
+//
+// The payload is walPut, the index as uvarint and the encoded value, or walDelete and the index.
+// The writes of a transaction are one walBatch record: walBatch, the count of writes as uvarint and
+// the length as uvarint and the payload of each write, so a torn transaction is dropped as a whole.
+// A checkpoint writes the skip list by WriteTo to the snapshot file of a new generation, then the logs
+// of older generations are removed. Open loads the latest snapshot and replays the logs after it.
+type wal struct {
+	mutex sync.Mutex
+	// checkpointMutex serializes the checkpoints.
+	checkpointMutex sync.Mutex
+	options         WALOptions
+	codec           Codec
+	file            *os.File
+	generation      uint64
+	// unsynced is the count of writes after the last sync.
+	unsynced int
+	// err is the first error of writing the log, the writes after it are not durable.
+	err  error
+	stop chan struct{}
+	done chan struct{}
+}
+
+// walName and snapshotName will return the file name of the log and the snapshot of given generation.
+func walName(generation uint64) string {
+	return fmt.Sprintf("wal-%020d.log", generation)
+}
+
+func snapshotName(generation uint64) string {
+	return fmt.Sprintf("snapshot-%020d", generation)
+}
+
+// encode will return the payload of the write of the index. Nil value means deletion.
+func (w *wal) encode(index uint64, value interface{}) ([]byte, error) {
+	payload := []byte{walPut}
+	if value == nil {
+		payload[0] = walDelete
+	}
+
+	payload = binary.AppendUvarint(payload, index)
+	if value != nil {
+		data, err := w.codec.Encode(value)
+		if err != nil {
+			return nil, err
+		}
+
+		payload = append(payload, data...)
+	}
+
+	return payload, nil
+}
+
+// append will append the write of the index to the log before it's applied. Nil value means deletion.
+// It's called under the write lock of the shard, so the records of an index are in the same order as the writes.
+func (w *wal) append(index uint64, value interface{}) {
+	payload, err := w.encode(index, value)
+	if err != nil {
+		w.fail(err)
+		return
+	}
+
+	w.write(payload, 1)
+}
+
+// collect will add the payload of the write of the index to the writes of a transaction.
+func (w *wal) collect(payloads *[][]byte, index uint64, value interface{}) {
+	payload, err := w.encode(index, value)
+	if err != nil {
+		w.fail(err)
+		return
+	}
+
+	*payloads = append(*payloads, payload)
+}
+
+// appendBatch will append the writes of a transaction to the log as one record.
+// It's called under the write locks of the shards of the transaction.
+func (w *wal) appendBatch(payloads [][]byte) {
+	if len(payloads) == 0 {
+		return
+	}
+
+	batch := binary.AppendUvarint([]byte{walBatch}, uint64(len(payloads)))
+	for _, payload := range payloads {
+		batch = binary.AppendUvarint(batch, uint64(len(payload)))
+		batch = append(batch, payload...)
+	}
+
+	w.write(batch, len(payloads))
+}
+
+// write will write the record of the payload and sync the log by the sync policy, writes is the count of
+// writes in the record.
+func (w *wal) write(payload []byte, writes int) {
+	record := make([]byte, walHeaderSize, walHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(record, uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:], crc32.Checksum(payload, castagnoli))
+	record = append(record, payload...)
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.err != nil {
+		return
+	}
+
+	if _, err := w.file.Write(record); err != nil {
+		w.err = err
+		return
+	}
+
+	w.unsynced += writes
+	if w.options.Sync == SyncAlways || (w.options.Sync == SyncBatch && w.unsynced >= w.options.BatchSize) {
+		w.syncLocked()
+	}
+}
+
+// fail will keep the first error of writing the log.
+func (w *wal) fail(err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+// syncLocked will sync the log if there are unsynced writes. The caller must hold the mutex.
+func (w *wal) syncLocked() {
+	if w.err != nil || w.unsynced == 0 {
+		return
+	}
+
+	w.unsynced = 0
+	w.err = w.file.Sync()
+}
+
+// sync will sync the log and return the first error of writing the log.
+func (w *wal) sync() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.syncLocked()
+	return w.err
+}
+
+// syncPeriodically will sync the log every interval until stopped.
+func (w *wal) syncPeriodically() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.sync()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// rotate will sync and close the log, then create the log of the next generation and return the generation.
+func (w *wal) rotate() (uint64, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.syncLocked()
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	file, err := os.OpenFile(filepath.Join(w.options.Dir, walName(w.generation+1)), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := w.file.Close(); err != nil {
+		file.Close()
+		return 0, err
+	}
+
+	w.file = file
+	w.generation++
+	return w.generation, syncDir(w.options.Dir)
+}
+
+// syncDir will sync the directory, so the created, renamed and removed files are durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
+
+// generations will return the generations of the logs and the snapshots in the directory in ascending order.
+func generations(dir string) (logs []uint64, snapshots []uint64, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, entry := range entries {
+		var generation uint64
+		if _, err := fmt.Sscanf(entry.Name(), "wal-%020d.log", &generation); err == nil && entry.Name() == walName(generation) {
+			logs = append(logs, generation)
+		} else if _, err := fmt.Sscanf(entry.Name(), "snapshot-%020d", &generation); err == nil && entry.Name() == snapshotName(generation) {
+			snapshots = append(snapshots, generation)
+		}
+	}
+
+	for _, generations := range [][]uint64{logs, snapshots} {
+		sort.Slice(generations, func(i, j int) bool {
+			return generations[i] < generations[j]
+		})
+	}
+
+	return logs, snapshots, nil
+}
+
+// removeBefore will remove the logs and the snapshots whose generation is less than given generation.
+func removeBefore(dir string, generation uint64) error {
+	logs, snapshots, err := generations(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, g := range logs {
+		if g < generation {
+			if err := os.Remove(filepath.Join(dir, walName(g))); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The snapshots being written when crashing are incomplete.
+	temps, err := filepath.Glob(filepath.Join(dir, "snapshot-*.tmp"))
+	if err != nil {
+		return err
+	}
+
+	for _, temp := range temps {
+		if err := os.Remove(temp); err != nil {
+			return err
+		}
+	}
+
+	for _, g := range snapshots {
+		if g < generation {
+			if err := os.Remove(filepath.Join(dir, snapshotName(g))); err != nil {
+				return err
+			}
+		}
+	}
+
+	return syncDir(dir)
+}
+
+// replay will apply the records of the log file to the skip list. If last is true, the log is the newest one,
+// a torn record at its end is the record being written when crashing, so the file is truncated before it.
+// The older logs are synced when rotated, so a torn record in them is corruption. A corrupted record followed
+// by more records is corruption too, an error is returned for it rather than dropping the valid records after it.
+func (s *ConcurrentSkipList) replay(path string, last bool) error {
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(file)
+	var offset int64
+	header := make([]byte, walHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, header); err == io.EOF {
+			return nil
+		} else if err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		// A record running past the end of the file is torn.
+		length := int64(binary.LittleEndian.Uint32(header))
+		end := offset + walHeaderSize + length
+		if end > info.Size() {
+			break
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return err
+		}
+
+		if length == 0 || crc32.Checksum(payload, castagnoli) != binary.LittleEndian.Uint32(header[4:]) {
+			// A torn record may be partly written, so only the record at the end of the file is torn.
+			if end < info.Size() {
+				return fmt.Errorf("invalid log, record at offset %d of %s is corrupted", offset, path)
+			}
+
+			break
+		}
+
+		if err := s.apply(payload); err != nil {
+			return err
+		}
+
+		offset = end
+	}
+
+	if !last {
+		return fmt.Errorf("invalid log, record at offset %d of %s is torn", offset, path)
+	}
+
+	if err := file.Truncate(offset); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// apply will apply the payload of a log record to the skip list.
+// The writes of a batch are all decoded before any of them is applied.
+func (s *ConcurrentSkipList) apply(payload []byte) error {
+	if payload[0] != walBatch {
+		entry, err := s.decodeWrite(payload)
+		if err != nil {
+			return err
+		}
+
+		s.applyWrite(entry)
+		return nil
+	}
+
+	count, n := binary.Uvarint(payload[1:])
+	if n <= 0 || count > uint64(len(payload)) {
+		return errors.New("invalid log, count of batch is corrupted")
+	}
+
+	rest := payload[1+n:]
+	entries := make([]Entry, 0, count)
+	for i := uint64(0); i < count; i++ {
+		length, n := binary.Uvarint(rest)
+		if n <= 0 || length == 0 || length > uint64(len(rest)-n) {
+			return errors.New("invalid log, write of batch is corrupted")
+		}
+
+		entry, err := s.decodeWrite(rest[n : n+int(length)])
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, entry)
+		rest = rest[n+int(length):]
+	}
+
+	for _, entry := range entries {
+		s.applyWrite(entry)
+	}
+
+	return nil
+}
+
+// decodeWrite will decode the payload of a write, nil value means deletion.
+func (s *ConcurrentSkipList) decodeWrite(payload []byte) (Entry, error) {
+	index, n := binary.Uvarint(payload[1:])
+	if n <= 0 {
+		return Entry{}, errors.New("invalid log, index is corrupted")
+	}
+
+	switch payload[0] {
+	case walPut:
+		value, err := s.codec.Decode(payload[1+n:])
+		if err != nil {
+			return Entry{}, err
+		}
+
+		return Entry{Index: index, Value: value}, nil
+	case walDelete:
+		return Entry{Index: index}, nil
+	default:
+		return Entry{}, fmt.Errorf("invalid log, record %d is not supported", payload[0])
+	}
+}
+
+// applyWrite will apply a decoded write to the skip list.
+func (s *ConcurrentSkipList) applyWrite(entry Entry) {
+	if entry.Value == nil {
+		s.Delete(entry.Index)
+	} else {
+		s.Insert(entry.Index, entry.Value)
+	}
+}
+
+// Open will open a durable skip list with given options whose writes are logged in the directory of walOptions.
+// The latest checkpoint is loaded and the log after it is replayed. A torn record at the tail of the newest log,
+// which is being written when crashing, is dropped. Any other corrupted record returns an error, as the writes
+// after it can't be replayed in order. Options.Codec is required to encode the values.
+// Every write, including the evictions and the expirations, appends a record to the log before it's applied
+// to the shard, and the log is synced by the sync policy. If appending to the log fails, the error is kept
+// and returned by Sync, Checkpoint and Close, and the writes after it are not durable.
+// The writes of a transaction are logged as one record when it commits, so they are replayed all or nothing.
+// TTL is not supported, as the checkpoints don't keep the deadlines. Lock-free shards are not supported,
+// as the writes of an index must be logged in the same order as they are applied.
+// The log grows with the writes, call Checkpoint to write a snapshot and remove the log before it.
+// Close the skip list to sync and close the log.
+func Open(options Options, walOptions WALOptions) (*ConcurrentSkipList, error) {
+	if options.LockFree {
+		return nil, errors.New("lock-free skip list does not support write-ahead log")
+	}
+
+	if options.Codec == nil {
+		return nil, errors.New("invalid codec, codec is required to write log")
+	}
+
+	if walOptions.Dir == "" {
+		return nil, errors.New("invalid dir, dir must not be empty")
+	}
+
+	switch walOptions.Sync {
+	case SyncAlways:
+	case SyncBatch:
+		if walOptions.BatchSize <= 0 {
+			return nil, errors.New("invalid batch size, batch size must be greater than 0")
+		}
+	case SyncInterval:
+		if walOptions.Interval <= 0 {
+			return nil, errors.New("invalid interval, interval must be greater than 0")
+		}
+	default:
+		return nil, errors.New("invalid sync, sync must be one of the sync policies")
+	}
+
+	s, err := NewConcurrentSkipListWithOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(walOptions.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	logs, snapshots, err := generations(walOptions.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// The evictions are replayed from the log, so don't evict while replaying. Don't split or merge
+	// in background either, as the new shards must be created after the log is attached.
+	s.capacity = 0
+	s.splitLength, s.splitContention, s.mergeLength = 0, 0, 0
+	var checkpoint uint64
+	if len(snapshots) > 0 {
+		checkpoint = snapshots[len(snapshots)-1]
+		if err := s.load(filepath.Join(walOptions.Dir, snapshotName(checkpoint))); err != nil {
+			return nil, err
+		}
+	}
+
+	// If crashing while checkpointing, there may be logs of newer generations than the snapshot.
+	generation := checkpoint
+	for i, g := range logs {
+		if g < checkpoint {
+			continue
+		}
+
+		if err := s.replay(filepath.Join(walOptions.Dir, walName(g)), i == len(logs)-1); err != nil {
+			return nil, err
+		}
+
+		generation = g
+	}
+
+	if err := removeBefore(walOptions.Dir, checkpoint); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(filepath.Join(walOptions.Dir, walName(generation)), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &wal{
+		options:    walOptions,
+		codec:      options.Codec,
+		file:       file,
+		generation: generation,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if walOptions.Sync == SyncInterval {
+		go w.syncPeriodically()
+	} else {
+		close(w.done)
+	}
+
+	// The skip list is not shared yet, so attach the log to the shards directly.
+	s.wal = w
+	for _, sl := range s.loadTable().skipLists {
+		sl.(*skipList).wal = w
+	}
+
+	s.capacity = options.Capacity
+	s.splitLength, s.splitContention, s.mergeLength = options.SplitLength, options.SplitContention, options.MergeLength
+	s.Balance()
+	s.checkCapacity()
+	return s, nil
+}
+
+// load will read the snapshot file into the skip list.
+func (s *ConcurrentSkipList) load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = s.ReadFrom(file)
+	return err
+}
+
+// Checkpoint will write a snapshot of the skip list to the directory of log and remove the log before it,
+// so the log doesn't grow forever and opening replays less. The log is rotated first, then the snapshot is
+// taken, so the writes in the old log are all in the snapshot, and the writes in the new log are replayed
+// after loading the snapshot. The writes continue while writing the snapshot.
+func (s *ConcurrentSkipList) Checkpoint() error {
+	if s.wal == nil {
+		return errors.New("skip list is not durable, use Open to create it")
+	}
+
+	s.wal.checkpointMutex.Lock()
+	defer s.wal.checkpointMutex.Unlock()
+
+	generation, err := s.wal.rotate()
+	if err != nil {
+		return err
+	}
+
+	dir := s.wal.options.Dir
+	temp := filepath.Join(dir, snapshotName(generation)+".tmp")
+	file, err := os.Create(temp)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.WriteTo(file); err != nil {
+		file.Close()
+		os.Remove(temp)
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(temp)
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(temp)
+		return err
+	}
+
+	if err := os.Rename(temp, filepath.Join(dir, snapshotName(generation))); err != nil {
+		return err
+	}
+
+	return removeBefore(dir, generation)
+}
+
+// Sync will sync the log to the disk regardless of the sync policy,
+// and return the first error of writing the log if any.
+func (s *ConcurrentSkipList) Sync() error {
+	if s.wal == nil {
+		return errors.New("skip list is not durable, use Open to create it")
+	}
+
+	return s.wal.sync()
+}
+
+// Close will sync and close the log. The writes after closing are not durable.
+// Return the first error of writing the log if any.
+func (s *ConcurrentSkipList) Close() error {
+	if s.wal == nil {
+		return errors.New("skip list is not durable, use Open to create it")
+	}
+
+	w := s.wal
+	w.mutex.Lock()
+	select {
+	case <-w.stop:
+		w.mutex.Unlock()
+		return errors.New("skip list is closed")
+	default:
+		close(w.stop)
+	}
+
+	w.syncLocked()
+	err := w.err
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if w.err == nil {
+		w.err = errors.New("write-ahead log is closed")
+	}
+	w.mutex.Unlock()
+
+	<-w.done
+	return err
+}
@@ -0,0 +1,319 @@
+package ConcurrentSkipList
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// entriesOf will return the index and value of each node.
+func entriesOf(s *ConcurrentSkipList) map[uint64]interface{} {
+	result := make(map[uint64]interface{})
+	for index, value := range s.All() {
+		result[index] = value
+	}
+
+	return result
+}
+
+// equalEntries will check whether the two skip lists have the same nodes.
+func equalEntries(t *testing.T, got, want *ConcurrentSkipList) {
+	t.Helper()
+	a, b := entriesOf(got), entriesOf(want)
+	if len(a) != len(b) {
+		t.Fatalf("got %d nodes, want %d", len(a), len(b))
+	}
+
+	for index, value := range b {
+		if a[index] != value {
+			t.Fatalf("value of %d = %v, want %v", index, a[index], value)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	options := Options{MaxLevel: 12, Shards: 4, Codec: intCodec{}}
+
+	t.Run("test replay", func(t *testing.T) {
+		dir := t.TempDir()
+		skipList, err := Open(options, WALOptions{Dir: dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 100; i++ {
+			skipList.Insert(uint64(i), i)
+		}
+
+		skipList.Delete(3)
+		skipList.InsertBatch([]Entry{{Index: 200, Value: 200}, {Index: 4, Value: 40}})
+		skipList.Compute(5, func(old interface{}, exists bool) (interface{}, bool) {
+			return old.(int) + 1, true
+		})
+		_ = skipList.Update(func(tx *Txn) error {
+			tx.Delete(6)
+			tx.Insert(300, 300)
+			return nil
+		})
+		skipList.PopFirst()
+
+		// Reopen without closing like crashing.
+		reopened, err := Open(options, WALOptions{Dir: dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		equalEntries(t, reopened, skipList)
+		if err := skipList.Close(); err != nil {
+			t.Errorf("Close() error = %v", err)
+		}
+
+		if err := skipList.Close(); err == nil {
+			t.Errorf("Close() should return an error when closed")
+		}
+
+		if err := reopened.Sync(); err != nil {
+			t.Errorf("Sync() error = %v", err)
+		}
+	})
+
+	t.Run("test torn tail", func(t *testing.T) {
+		dir := t.TempDir()
+		skipList, _ := Open(options, WALOptions{Dir: dir})
+		for i := 0; i < 10; i++ {
+			skipList.Insert(uint64(i), i)
+		}
+
+		skipList.Close()
+
+		path := filepath.Join(dir, walName(0))
+		info, _ := os.Stat(path)
+		file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+		file.Write([]byte{10, 0, 0, 0, 1, 2, 3})
+		file.Close()
+
+		reopened, err := Open(options, WALOptions{Dir: dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		equalEntries(t, reopened, skipList)
+		if truncated, _ := os.Stat(path); truncated.Size() != info.Size() {
+			t.Errorf("the torn record should be truncated, size = %d, want %d", truncated.Size(), info.Size())
+		}
+
+		reopened.Insert(10, 10)
+		reopened.Close()
+		again, _ := Open(options, WALOptions{Dir: dir})
+		if node, ok := again.Search(10); !ok || node.Value() != 10 {
+			t.Errorf("the write after the truncated log should be replayed")
+		}
+	})
+
+	t.Run("test corruption", func(t *testing.T) {
+		dir := t.TempDir()
+		skipList, _ := Open(options, WALOptions{Dir: dir})
+		for i := 0; i < 3; i++ {
+			skipList.Insert(uint64(i), i)
+		}
+
+		skipList.Close()
+
+		// Flip a byte of the CRC of the second record, the records are the same size.
+		path := filepath.Join(dir, walName(0))
+		data, _ := os.ReadFile(path)
+		size := len(data) / 3
+		data[size+4] ^= 0xff
+		os.WriteFile(path, data, 0644)
+
+		if _, err := Open(options, WALOptions{Dir: dir}); err == nil {
+			t.Errorf("Open() should return an error for the corrupted record before the end")
+		}
+
+		if info, _ := os.Stat(path); info.Size() != int64(len(data)) {
+			t.Errorf("the corrupted log should not be truncated, size = %d, want %d", info.Size(), len(data))
+		}
+
+		// A corrupted record at the end of the newest log is torn.
+		data[size+4] ^= 0xff
+		data[2*size+4] ^= 0xff
+		os.WriteFile(path, data, 0644)
+		reopened, err := Open(options, WALOptions{Dir: dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if reopened.Length() != 2 {
+			t.Errorf("Length() = %d, want 2", reopened.Length())
+		}
+
+		reopened.Close()
+	})
+
+	t.Run("test torn old generation", func(t *testing.T) {
+		dir := t.TempDir()
+		skipList, _ := Open(options, WALOptions{Dir: dir})
+		skipList.Insert(1, 1)
+		skipList.Close()
+
+		file, _ := os.OpenFile(filepath.Join(dir, walName(0)), os.O_WRONLY|os.O_APPEND, 0644)
+		file.Write([]byte{10, 0, 0, 0, 1, 2, 3})
+		file.Close()
+		os.WriteFile(filepath.Join(dir, walName(1)), nil, 0644)
+
+		if _, err := Open(options, WALOptions{Dir: dir}); err == nil {
+			t.Errorf("Open() should return an error for the torn record of an older log")
+		}
+	})
+
+	t.Run("test transaction", func(t *testing.T) {
+		dir := t.TempDir()
+		skipList, _ := Open(options, WALOptions{Dir: dir})
+		skipList.Insert(1, 1)
+		_ = skipList.Update(func(tx *Txn) error {
+			tx.Delete(1)
+			tx.Insert(2, 2)
+			tx.Insert(shardIndexes[2], 3)
+			return nil
+		})
+		skipList.Close()
+
+		// Tear the record of the transaction, none of its writes is replayed.
+		path := filepath.Join(dir, walName(0))
+		info, _ := os.Stat(path)
+		os.Truncate(path, info.Size()-1)
+		reopened, err := Open(options, WALOptions{Dir: dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if node, ok := reopened.Search(1); !ok || node.Value() != 1 || reopened.Length() != 1 {
+			t.Errorf("the torn transaction should be dropped as a whole, got %v", entriesOf(reopened))
+		}
+
+		reopened.Close()
+	})
+
+	t.Run("test TTL", func(t *testing.T) {
+		skipList, _ := Open(options, WALOptions{Dir: t.TempDir()})
+		defer skipList.Close()
+
+		if err := skipList.InsertWithTTL(1, 1, time.Minute); err == nil {
+			t.Errorf("InsertWithTTL() should return an error for durable skip list")
+		}
+	})
+
+	t.Run("test split while replaying", func(t *testing.T) {
+		dir := t.TempDir()
+		options := Options{MaxLevel: 12, Shards: 1, SplitLength: 4, Codec: VarintCodec{}}
+		for i := 0; i < 5; i++ {
+			list, err := Open(options, WALOptions{Dir: dir})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if list.Length() != int32(i*10) {
+				t.Fatalf("Length() = %d after reopening, want %d", list.Length(), i*10)
+			}
+
+			for j := 0; j < 10; j++ {
+				list.Insert(uint64(i*10+j), i*10+j)
+			}
+
+			list.Balance()
+			for _, sl := range list.loadTable().skipLists {
+				if sl.(*skipList).wal == nil {
+					t.Fatalf("the shards should have the log after splitting")
+				}
+			}
+
+			list.Close()
+		}
+	})
+
+	t.Run("test read error", func(t *testing.T) {
+		dir := t.TempDir()
+		os.Mkdir(filepath.Join(dir, walName(0)), 0755)
+
+		if _, err := Open(options, WALOptions{Dir: dir}); err == nil {
+			t.Errorf("Open() should return an error when the log can't be read")
+		}
+	})
+
+	t.Run("test checkpoint", func(t *testing.T) {
+		dir := t.TempDir()
+		skipList, _ := Open(options, WALOptions{Dir: dir, Sync: SyncBatch, BatchSize: 16})
+		wg := &sync.WaitGroup{}
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 500; j++ {
+					index := uint64(j % 100 * 4)
+					if j%3 == 0 {
+						skipList.Delete(index)
+					} else {
+						skipList.Insert(index+uint64(i), j)
+					}
+				}
+			}(i)
+		}
+
+		for i := 0; i < 3; i++ {
+			if err := skipList.Checkpoint(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		wg.Wait()
+		if err := skipList.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		logs, snapshots, _ := generations(dir)
+		if len(logs) != 1 || len(snapshots) != 1 || logs[0] != 3 || snapshots[0] != 3 {
+			t.Errorf("logs = %v, snapshots = %v, want the third generation", logs, snapshots)
+		}
+
+		reopened, err := Open(options, WALOptions{Dir: dir, Sync: SyncInterval, Interval: time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		equalEntries(t, reopened, skipList)
+		reopened.Close()
+	})
+
+	t.Run("test codec error", func(t *testing.T) {
+		skipList, _ := Open(options, WALOptions{Dir: t.TempDir()})
+		skipList.Insert(1, "string")
+		if err := skipList.Sync(); err == nil {
+			t.Errorf("Sync() should return the error of codec, got %v", err)
+		}
+	})
+
+	t.Run("test invalid", func(t *testing.T) {
+		for _, c := range []struct {
+			options    Options
+			walOptions WALOptions
+		}{
+			{Options{MaxLevel: 12, Codec: intCodec{}, LockFree: true}, WALOptions{Dir: t.TempDir()}},
+			{Options{MaxLevel: 12}, WALOptions{Dir: t.TempDir()}},
+			{options, WALOptions{}},
+			{options, WALOptions{Dir: t.TempDir(), Sync: SyncBatch}},
+			{options, WALOptions{Dir: t.TempDir(), Sync: SyncInterval}},
+			{options, WALOptions{Dir: t.TempDir(), Sync: SyncInterval + 1}},
+		} {
+			if _, err := Open(c.options, c.walOptions); err == nil {
+				t.Errorf("Open(%+v, %+v) should return an error", c.options, c.walOptions)
+			}
+		}
+
+		skipList, _ := NewConcurrentSkipList(12)
+		if skipList.Checkpoint() == nil || skipList.Sync() == nil || skipList.Close() == nil {
+			t.Errorf("the skip list is not durable")
+		}
+	})
+}
@@ -0,0 +1,274 @@
+package ConcurrentSkipList
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// EventType is the type of a change of skip list.
+type EventType int
+
+const (
+	// EventInsert means the index is inserted.
+	EventInsert EventType = iota
+	// EventUpdate means the value of the index is replaced.
+	EventUpdate
+	// EventDelete means the index is deleted, including the evictions and the expirations.
+	EventDelete
+	// EventError means the watch is closed by an error, it's the last event of the channel.
+	EventError
+)
+
+// Event is a change of skip list delivered by Watch.
+type Event struct {
+	Type  EventType
+	Index uint64
+	// Value is the value after the change, nil for EventDelete.
+	Value interface{}
+	// Previous is the value before the change, nil for EventInsert.
+	Previous interface{}
+	// Err is the error closing the watch for EventError.
+	Err error
+}
+
+// WatchPolicy decides what to do when the buffer of a watch is full as the consumer is slow.
+type WatchPolicy int
+
+const (
+	// WatchDrop drops the events which don't fit in the buffer.
+	WatchDrop WatchPolicy = iota
+	// WatchBlock blocks the writer until the consumer receives the event or cancels the watch.
+	// The writer has released the lock of the shard, but the later writes of the shard are not published
+	// until the event is received, so the consumer should keep receiving.
+	WatchBlock
+	// WatchClose closes the watch with ErrSlowConsumer.
+	WatchClose
+)
+
+// WATCH_BUFFER is the default size of the buffer of a watch.
+const WATCH_BUFFER = 64
+
+// ErrSlowConsumer is the error of EventError when the watch is closed by WatchClose.
+var ErrSlowConsumer = errors.New("watch is closed, the consumer is too slow")
+
+// watcher is a watch of the indexes in [lo, hi).
+type watcher struct {
+	lo, hi uint64
+	policy WatchPolicy
+	buffer int
+	// events has one more slot than buffer for the last EventError.
+	events chan Event
+	// mutex serializes sending and closing events.
+	mutex  sync.Mutex
+	closed bool
+	// done is closed when the watch is canceled, so the blocked writer returns.
+	done chan struct{}
+	once sync.Once
+}
+
+// send will deliver the event by the policy. Return false if the watch is closed by WatchClose.
+func (w *watcher) send(e Event) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return true
+	}
+
+	switch w.policy {
+	case WatchBlock:
+		select {
+		case w.events <- e:
+		case <-w.done:
+		}
+	case WatchClose:
+		if len(w.events) >= w.buffer {
+			w.closeLocked(ErrSlowConsumer)
+			return false
+		}
+
+		w.events <- e
+	default:
+		if len(w.events) < w.buffer {
+			w.events <- e
+		}
+	}
+
+	return true
+}
+
+// closeLocked will close the channel of events. If err is not nil, EventError is sent before closing.
+// The caller must hold the mutex.
+func (w *watcher) closeLocked(err error) {
+	if w.closed {
+		return
+	}
+
+	if err != nil {
+		w.events <- Event{Type: EventError, Err: err}
+	}
+
+	w.closed = true
+	close(w.events)
+}
+
+// cancel will wake the blocked writer and close the channel of events.
+func (w *watcher) cancel() {
+	w.once.Do(func() {
+		close(w.done)
+	})
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.closeLocked(nil)
+}
+
+// watchers is the copy-on-write registry of the watches of a ConcurrentSkipList, shared by its shards.
+type watchers struct {
+	mutex sync.Mutex
+	list  atomic.Pointer[[]*watcher]
+}
+
+// active will return whether there is any watch, so the changes are not recorded without watches.
+func (ws *watchers) active() bool {
+	if ws == nil {
+		return false
+	}
+
+	list := ws.list.Load()
+	return list != nil && len(*list) > 0
+}
+
+// add will register the watch.
+func (ws *watchers) add(w *watcher) {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	var list []*watcher
+	if old := ws.list.Load(); old != nil {
+		list = append(list, *old...)
+	}
+
+	list = append(list, w)
+	ws.list.Store(&list)
+}
+
+// remove will unregister the watch.
+func (ws *watchers) remove(w *watcher) {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	var list []*watcher
+	if old := ws.list.Load(); old != nil {
+		for _, current := range *old {
+			if current != w {
+				list = append(list, current)
+			}
+		}
+	}
+
+	ws.list.Store(&list)
+}
+
+// publish will deliver the events to the watches of their indexes.
+func (ws *watchers) publish(events []Event) {
+	list := ws.list.Load()
+	if list == nil {
+		return
+	}
+
+	for _, w := range *list {
+		for _, e := range events {
+			if e.Index < w.lo || e.Index >= w.hi {
+				continue
+			}
+
+			if !w.send(e) {
+				ws.remove(w)
+				break
+			}
+		}
+	}
+}
+
+// emit will record the change of the index for the watches, nil means the index doesn't exist.
+// The events are published after the write lock is released. The caller must hold the write lock.
+func (s *skipList) emit(index uint64, old, value interface{}) {
+	if !s.watch.active() {
+		return
+	}
+
+	e := Event{Type: EventUpdate, Index: index, Value: value, Previous: old}
+	if old == nil {
+		e.Type = EventInsert
+	} else if value == nil {
+		e.Type = EventDelete
+	}
+
+	s.eventsMutex.Lock()
+	s.events = append(s.events, e)
+	s.eventsMutex.Unlock()
+	s.emitted = true
+}
+
+// unlock will release the write lock, then publish the events of the writes.
+func (s *skipList) unlock() {
+	if s.release() {
+		s.publish()
+	}
+}
+
+// release will release the write lock and return whether the writes have events to publish.
+func (s *skipList) release() bool {
+	emitted := s.emitted
+	s.emitted = false
+	s.mutex.Unlock()
+	return emitted
+}
+
+// publish will publish the pending events of the shard. The events are taken and published under publishMutex,
+// so they are published in the order of the writes even if the writers publish concurrently.
+// It must be called without the write lock, so no lock is held while the slow consumers block.
+func (s *skipList) publish() {
+	s.publishMutex.Lock()
+	defer s.publishMutex.Unlock()
+
+	s.eventsMutex.Lock()
+	events := s.events
+	s.events = nil
+	s.eventsMutex.Unlock()
+
+	s.watch.publish(events)
+}
+
+// Watch will return a channel delivering the insertions, updates and deletions of the indexes in [lo, hi),
+// and a function to cancel the watch. The events are sent after the writes release the lock of shard,
+// the events of an index are in the order of its writes, but the events of different shards may interleave.
+// The channel is buffered by Options.WatchBuffer, and Options.WatchPolicy decides what to do when the buffer
+// is full. The channel is closed when the watch is canceled, or after EventError if the watch is closed
+// by an error. Lock-free shards don't record the changes, so an EventError is sent for them.
+func (s *ConcurrentSkipList) Watch(lo, hi uint64) (<-chan Event, func()) {
+	w := &watcher{
+		lo:     lo,
+		hi:     hi,
+		policy: s.watchPolicy,
+		buffer: s.watchBuffer,
+		events: make(chan Event, s.watchBuffer+1),
+		done:   make(chan struct{}),
+	}
+
+	if s.lockFree {
+		w.mutex.Lock()
+		w.closeLocked(errors.New("lock-free skip list does not support watch"))
+		w.mutex.Unlock()
+		return w.events, func() {}
+	}
+
+	s.watch.add(w)
+	return w.events, func() {
+		s.watch.remove(w)
+		w.cancel()
+	}
+}
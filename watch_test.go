@@ -0,0 +1,235 @@
+package ConcurrentSkipList
+
+import (
+	"testing"
+	"time"
+)
+
+// receive will receive count events from the channel or fail after a second.
+func receive(t *testing.T, events <-chan Event, count int) []Event {
+	t.Helper()
+	var result []Event
+	for len(result) < count {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				t.Fatalf("the channel is closed after %d events, want %d", len(result), count)
+			}
+
+			result = append(result, e)
+		case <-time.After(time.Second):
+			t.Fatalf("received %d events, want %d", len(result), count)
+		}
+	}
+
+	return result
+}
+
+func TestConcurrentSkipList_Watch(t *testing.T) {
+	t.Run("test events", func(t *testing.T) {
+		skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, Shards: 4})
+		events, cancel := skipList.Watch(0, 100)
+		defer cancel()
+
+		skipList.Insert(1, "a")
+		skipList.Insert(1, "b")
+		skipList.Insert(200, "out of range")
+		skipList.Delete(1)
+		skipList.Delete(2)
+		skipList.InsertBatch([]Entry{{Index: 3, Value: 3}, {Index: 4, Value: 4}})
+		skipList.Compute(3, func(old interface{}, exists bool) (interface{}, bool) {
+			return nil, false
+		})
+
+		want := []Event{
+			{Type: EventInsert, Index: 1, Value: "a"},
+			{Type: EventUpdate, Index: 1, Value: "b", Previous: "a"},
+			{Type: EventDelete, Index: 1, Previous: "b"},
+			{Type: EventInsert, Index: 3, Value: 3},
+			{Type: EventInsert, Index: 4, Value: 4},
+			{Type: EventDelete, Index: 3, Previous: 3},
+		}
+		got := receive(t, events, len(want))
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("event %d = %v, want %v", i, got[i], want[i])
+			}
+		}
+
+		select {
+		case e := <-events:
+			t.Errorf("unexpected event %v", e)
+		default:
+		}
+	})
+
+	t.Run("test transaction and expiration", func(t *testing.T) {
+		skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, Shards: 4})
+		events, cancel := skipList.Watch(0, ^uint64(0))
+		defer cancel()
+
+		_ = skipList.Update(func(tx *Txn) error {
+			tx.Insert(shardIndexes[1], 1)
+			return nil
+		})
+		_ = skipList.InsertWithTTL(5, 5, 10*time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+		skipList.Search(5)
+
+		got := receive(t, events, 3)
+		if got[0].Index != shardIndexes[1] || got[0].Type != EventInsert {
+			t.Errorf("event of transaction = %v", got[0])
+		}
+
+		if got[2].Index != 5 || got[2].Type != EventDelete {
+			t.Errorf("event of expiration = %v", got[2])
+		}
+	})
+
+	t.Run("test cancel", func(t *testing.T) {
+		skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12})
+		events, cancel := skipList.Watch(0, 100)
+		cancel()
+		cancel()
+
+		skipList.Insert(1, 1)
+		if _, ok := <-events; ok {
+			t.Errorf("the channel should be closed after cancel")
+		}
+	})
+
+	t.Run("test lock-free", func(t *testing.T) {
+		skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, LockFree: true})
+		events, cancel := skipList.Watch(0, 100)
+		defer cancel()
+
+		if e := <-events; e.Type != EventError || e.Err == nil {
+			t.Errorf("event = %v, want EventError", e)
+		}
+
+		if _, ok := <-events; ok {
+			t.Errorf("the channel should be closed after EventError")
+		}
+	})
+}
+
+func TestConcurrentSkipList_WatchPolicy(t *testing.T) {
+	t.Run("test drop", func(t *testing.T) {
+		skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, WatchBuffer: 2})
+		events, cancel := skipList.Watch(0, 100)
+		defer cancel()
+
+		for i := uint64(0); i < 5; i++ {
+			skipList.Insert(i, i)
+		}
+
+		got := receive(t, events, 2)
+		if got[0].Index != 0 || got[1].Index != 1 {
+			t.Errorf("events = %v, want the first 2 events", got)
+		}
+
+		skipList.Insert(6, 6)
+		if got = receive(t, events, 1); got[0].Index != 6 {
+			t.Errorf("event = %v, want index 6", got[0])
+		}
+	})
+
+	t.Run("test block", func(t *testing.T) {
+		skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, WatchBuffer: 1, WatchPolicy: WatchBlock})
+		events, cancel := skipList.Watch(0, 100)
+		defer cancel()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := uint64(0); i < 10; i++ {
+				skipList.Insert(i, i)
+			}
+		}()
+
+		got := receive(t, events, 10)
+		for i, e := range got {
+			if e.Index != uint64(i) {
+				t.Errorf("event %d = %v", i, e)
+			}
+		}
+
+		<-done
+	})
+
+	t.Run("test block transaction", func(t *testing.T) {
+		skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, Shards: 2, WatchBuffer: 1, WatchPolicy: WatchBlock})
+		events, cancel := skipList.Watch(0, ^uint64(0))
+		defer cancel()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = skipList.Update(func(tx *Txn) error {
+				for i := uint64(0); i < 5; i++ {
+					tx.Insert(i, i)
+				}
+
+				tx.Insert(1<<63, 1)
+				return nil
+			})
+		}()
+
+		receive(t, events, 1)
+		// The consumer reads the list while the transaction is blocked, no shard should be locked.
+		searched := make(chan struct{})
+		go func() {
+			defer close(searched)
+			skipList.Search(1 << 63)
+		}()
+
+		select {
+		case <-searched:
+		case <-time.After(time.Second):
+			t.Fatalf("Search() should not be blocked by the transaction")
+		}
+
+		receive(t, events, 5)
+		<-done
+	})
+
+	t.Run("test block cancel", func(t *testing.T) {
+		skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, WatchBuffer: 1, WatchPolicy: WatchBlock})
+		_, cancel := skipList.Watch(0, 100)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := uint64(0); i < 10; i++ {
+				skipList.Insert(i, i)
+			}
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("the writer should not be blocked after cancel")
+		}
+	})
+
+	t.Run("test close", func(t *testing.T) {
+		skipList, _ := NewConcurrentSkipListWithOptions(Options{MaxLevel: 12, WatchBuffer: 2, WatchPolicy: WatchClose})
+		events, cancel := skipList.Watch(0, 100)
+		defer cancel()
+
+		for i := uint64(0); i < 5; i++ {
+			skipList.Insert(i, i)
+		}
+
+		got := receive(t, events, 3)
+		if got[0].Index != 0 || got[1].Index != 1 || got[2].Type != EventError || got[2].Err != ErrSlowConsumer {
+			t.Errorf("events = %v, want 2 events and ErrSlowConsumer", got)
+		}
+
+		if _, ok := <-events; ok {
+			t.Errorf("the channel should be closed after EventError")
+		}
+	})
+}